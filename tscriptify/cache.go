@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Cached helpers unused for this long are removed.
+const helperCacheMaxAge = 7 * 24 * time.Hour
+
+// libraryPath is the import path of the converter used by the helper.
+const libraryPath = "github.com/amanbolat/go-tscriptify/typescriptify"
+
+// helperCache keeps compiled helper programs in the user cache directory, or
+// in $TSCRIPTIFY_CACHE. A helper is keyed by everything it is built from: its
+// source, which includes the types, options and targets, the Go environment,
+// and the sources of all packages it imports. Next to it the hashes of the
+// targets it wrote are kept, so a run with unchanged inputs and targets is
+// skipped.
+type helperCache struct {
+	dir string
+}
+
+func openHelperCache() (*helperCache, error) {
+	dir := os.Getenv("TSCRIPTIFY_CACHE")
+	if len(dir) == 0 {
+		userDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(userDir, "tscriptify")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	cache := &helperCache{dir: dir}
+	cache.prune()
+	return cache, nil
+}
+
+// key hashes the inputs of a helper program.
+func (c *helperCache) key(source []byte, params Params, tags string) (string, error) {
+	h := sha256.New()
+	h.Write(source)
+	fmt.Fprintf(h, "tags=%s\n", tags)
+
+	env, err := exec.Command("go", "env", "GOVERSION", "GOOS", "GOARCH", "GOFLAGS", "CGO_ENABLED").Output()
+	if err != nil {
+		return "", fmt.Errorf("Cannot read the Go environment: %s", err.Error())
+	}
+	h.Write(env)
+
+	if err := hashDependencies(h, params, tags); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32], nil
+}
+
+// hashDependencies adds the sources of all non standard packages the helper
+// imports. Packages of a module version are immutable, their version is enough.
+func hashDependencies(h hash.Hash, params Params, tags string) error {
+	args := []string{"list", "-deps", "-f", "{{if not .Standard}}{{.Dir}}|{{with .Module}}{{if not .Replace}}{{.Version}}{{end}}{{end}}{{end}}"}
+	if len(tags) > 0 {
+		args = append(args, "-tags", tags)
+	}
+	args = append(args, libraryPath)
+	for _, imp := range params.Imports {
+		args = append(args, imp.Path)
+	}
+
+	cmd := exec.Command("go", args...)
+	// The helper is built within the module of the models
+	if dir, err := packageDir(params.Imports[0].Path, tags); err == nil {
+		cmd.Dir = dir
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("Cannot list the packages used by the helper: %s", err.Error())
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) == 0 {
+			continue
+		}
+		fmt.Fprintln(h, line)
+		parts := strings.SplitN(line, "|", 2)
+		if len(parts) == 2 && len(parts[1]) > 0 {
+			continue
+		}
+		if err := hashGoFiles(h, parts[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hashGoFiles(h hash.Hash, dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".go" {
+			continue
+		}
+		fmt.Fprintln(h, f.Name())
+		if err := hashFile(h, filepath.Join(dir, f.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hashFile(h hash.Hash, fileName string) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(h, f)
+	return err
+}
+
+func (c *helperCache) binary(key string) string {
+	return filepath.Join(c.dir, key, executableName(helperBinary))
+}
+
+func executableName(name string) string {
+	if runtime.GOOS == "windows" {
+		return name + ".exe"
+	}
+	return name
+}
+
+func (c *helperCache) outputsFile(key string) string {
+	return filepath.Join(c.dir, key, "outputs")
+}
+
+// targetHashes returns a line with the hash of each target file.
+func targetHashes(config *Config) (string, error) {
+	var result strings.Builder
+	for _, target := range config.Targets {
+		h := sha256.New()
+		if err := hashFile(h, target.Output); err != nil {
+			return "", err
+		}
+		absPath, err := filepath.Abs(target.Output)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&result, "%s %x\n", absPath, h.Sum(nil))
+	}
+	return result.String(), nil
+}
+
+// upToDate reports whether the helper with this key already wrote the
+// targets, and they haven't been changed since.
+func (c *helperCache) upToDate(key string, config *Config) bool {
+	for _, target := range config.Targets {
+		if target.Output == stdoutTarget {
+			return false
+		}
+	}
+	recorded, err := ioutil.ReadFile(c.outputsFile(key))
+	if err != nil {
+		return false
+	}
+	current, err := targetHashes(config)
+	if err != nil {
+		return false
+	}
+	if current != string(recorded) {
+		return false
+	}
+	c.touch(key)
+	return true
+}
+
+// written records the targets written by the helper with this key.
+func (c *helperCache) written(key string, config *Config) {
+	for _, target := range config.Targets {
+		if target.Output == stdoutTarget {
+			return
+		}
+	}
+	hashes, err := targetHashes(config)
+	if err != nil {
+		return
+	}
+	ioutil.WriteFile(c.outputsFile(key), []byte(hashes), 0644)
+}
+
+func (c *helperCache) touch(key string) {
+	now := time.Now()
+	os.Chtimes(filepath.Join(c.dir, key), now, now)
+}
+
+// prune removes the helpers which haven't been used for a while.
+func (c *helperCache) prune() {
+	entries, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if e.IsDir() && time.Since(e.ModTime()) > helperCacheMaxAge {
+			os.RemoveAll(filepath.Join(c.dir, e.Name()))
+		}
+	}
+}
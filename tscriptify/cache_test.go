@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHelperCacheUpToDate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tscriptify")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	cache := &helperCache{dir: filepath.Join(dir, "cache")}
+	target := filepath.Join(dir, "models.ts")
+	config := &Config{Targets: []Target{{Output: target}}}
+	if err := os.MkdirAll(filepath.Join(cache.dir, "key"), 0755); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := ioutil.WriteFile(target, []byte("export interface A {}\n"), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+	if cache.upToDate("key", config) {
+		t.Error("expected targets not written by the helper to be out of date")
+	}
+
+	cache.written("key", config)
+	if !cache.upToDate("key", config) {
+		t.Error("expected the written targets to be up to date")
+	}
+	if cache.upToDate("other", config) {
+		t.Error("expected another key to be out of date")
+	}
+
+	if err := ioutil.WriteFile(target, []byte("export interface B {}\n"), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+	if cache.upToDate("key", config) {
+		t.Error("expected a changed target to be out of date")
+	}
+}
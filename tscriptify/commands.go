@@ -0,0 +1,397 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/amanbolat/go-tscriptify/typescriptify"
+	"gopkg.in/yaml.v2"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) int
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"gen", "Generate the TypeScript files", gen},
+		{"check", "Check that the TypeScript files are up to date, exit with status 1 if not", func(args []string) int { return verify("check", modeCheck, args) }},
+		{"diff", "Print what gen would change", func(args []string) int { return verify("diff", modeDiff, args) }},
+		{"list", "List the types which can be converted", list},
+		{"init", "Create a config file and a go:generate directive", initConfig},
+		{"restore", "List the backups of a TypeScript file, or restore one", restore},
+		{"help", "Print this help", help},
+	}
+}
+
+// run executes the command given by the first argument. Without a known
+// command the arguments are those of gen, as in older versions.
+func run(args []string) int {
+	if len(args) > 0 {
+		for _, c := range commands {
+			if c.name == args[0] {
+				return c.run(args[1:])
+			}
+		}
+	}
+	return gen(args)
+}
+
+func help(args []string) int {
+	fmt.Fprintln(os.Stderr, "Usage: tscriptify <command> [flags] [types, files or directories]")
+	fmt.Fprintln(os.Stderr)
+	w := tabwriter.NewWriter(os.Stderr, 0, 4, 2, ' ', 0)
+	for _, c := range commands {
+		fmt.Fprintf(w, "    %s\t%s\n", c.name, c.usage)
+	}
+	w.Flush()
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run tscriptify <command> -h for the flags of a command. Exit status is 0 on success, 1 if check found differences, 2 for usage errors and 3 if the conversion failed.")
+	return exitOK
+}
+
+// parseFlags parses the flags of a command, returning -1 if parsing
+// succeeded and the exit code otherwise.
+func parseFlags(fs *flag.FlagSet, args []string) int {
+	fs.SetOutput(os.Stderr)
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	return -1
+}
+
+func gen(args []string) int {
+	fs := flag.NewFlagSet("gen", flag.ContinueOnError)
+	var o options
+	var check, watchSources bool
+	o.register(fs)
+	o.registerReport(fs)
+	o.registerBackup(fs)
+	fs.BoolVar(&check, "check", false, "Same as the check command")
+	fs.BoolVar(&watchSources, "watch", false, "Keep running and regenerate the targets when their Go sources change")
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+
+	if check && watchSources {
+		return fail(usageErrorf("-watch and -check can't be used together"))
+	}
+	if err := o.validateReport(); err != nil {
+		return fail(err)
+	}
+
+	config, err := o.config(fs.Args())
+	if err != nil {
+		return fail(err)
+	}
+
+	if watchSources {
+		return watch(config)
+	}
+	if check {
+		return convert(config, modeCheck, len(o.report) > 0)
+	}
+	return convert(config, modeGen, len(o.report) > 0)
+}
+
+// verify compares the targets with what would be generated.
+func verify(name, mode string, args []string) int {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	var o options
+	o.register(fs)
+	o.registerReport(fs)
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+	if err := o.validateReport(); err != nil {
+		return fail(err)
+	}
+
+	config, err := o.config(fs.Args())
+	if err != nil {
+		return fail(err)
+	}
+	return convert(config, mode, len(o.report) > 0)
+}
+
+// listedType is a type printed by list.
+type listedType struct {
+	Package string      `json:"package"`
+	Name    string      `json:"name"`
+	Marked  bool        `json:"marked"`
+	Options TypeOptions `json:"options"`
+}
+
+// list prints the types discovered in the packages, those of the config by
+// default. Types named in the config are listed even if filtered out.
+func list(args []string) int {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	var o options
+	var format string
+	o.register(fs)
+	fs.StringVar(&format, "format", "text", "Output format, text or json")
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+	if format != "text" && format != "json" {
+		return fail(usageErrorf("Unknown format %s", format))
+	}
+
+	if len(o.target) == 0 {
+		// Not needed for discovering the types
+		o.target = "-"
+	}
+	config, err := o.config(fs.Args())
+	if err != nil {
+		return fail(err)
+	}
+	if err := config.discoverTypes(); err != nil {
+		return fail(err)
+	}
+
+	listed := make([]listedType, 0)
+	seen := make(map[string]bool)
+	for _, target := range config.Targets {
+		for _, pkg := range target.Packages {
+			for _, name := range pkg.Types {
+				if seen[pkg.Path+"."+name] {
+					continue
+				}
+				seen[pkg.Path+"."+name] = true
+				listed = append(listed, listedType{Package: pkg.Path, Name: name, Options: pkg.Options[name]})
+			}
+		}
+	}
+	if err := markListed(config, listed); err != nil {
+		return fail(err)
+	}
+
+	if format == "json" {
+		bytes, err := json.MarshalIndent(listed, "", "  ")
+		if err != nil {
+			return fail(err)
+		}
+		fmt.Println(string(bytes))
+		return exitOK
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, t := range listed {
+		var flags []string
+		if t.Marked {
+			flags = append(flags, "marked")
+		}
+		if len(t.Options.Name) > 0 {
+			flags = append(flags, "name="+t.Options.Name)
+		}
+		if t.Options.Interface {
+			flags = append(flags, "interface")
+		}
+		if t.Options.Class {
+			flags = append(flags, "class")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", t.Package, t.Name, strings.Join(flags, " "))
+	}
+	w.Flush()
+	return exitOK
+}
+
+// markListed sets Marked for the listed types having an export marker.
+func markListed(config *Config, listed []listedType) error {
+	marked := make(map[string]bool)
+	for _, pkgPath := range uniquePackages(config) {
+		dir, err := packageDir(pkgPath, config.Tags)
+		if err != nil {
+			return err
+		}
+		found, err := GetGolangTypes(dir, TypeFilter{})
+		if err != nil {
+			return err
+		}
+		for _, d := range found {
+			marked[pkgPath+"."+d.Name] = d.Marked
+		}
+	}
+	for n := range listed {
+		listed[n].Marked = marked[listed[n].Package+"."+listed[n].Name]
+	}
+	return nil
+}
+
+func uniquePackages(config *Config) []string {
+	seen := make(map[string]bool)
+	result := make([]string, 0)
+	for _, target := range config.Targets {
+		for _, pkg := range target.Packages {
+			if !seen[pkg.Path] {
+				seen[pkg.Path] = true
+				result = append(result, pkg.Path)
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// generateFile holds the go:generate directive written by init.
+const generateFile = "tscriptify_generate.go"
+
+// initConfig writes a config file in the current directory, and a Go file
+// with a go:generate directive running tscriptify into the models package.
+func initConfig(args []string) int {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	var packagePaths stringList
+	var target string
+	var marked, static, force bool
+	fs.Var(&packagePaths, "package", "Path of a package with models, can be repeated (default the package in the current directory)")
+	fs.StringVar(&target, "target", "models.ts", "Target typescript file")
+	fs.BoolVar(&marked, "marked", false, "Only convert types marked with a //tscriptify:export comment")
+	fs.BoolVar(&static, "static", false, "Load the models with go/types instead of compiling and running a helper program")
+	fs.BoolVar(&force, "force", false, "Overwrite existing files")
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+	if len(packagePaths) == 0 {
+		packagePaths = stringList{"."}
+	}
+
+	configFile := configFiles[0]
+	if existing := findConfig(); len(existing) > 0 && !force {
+		return fail(usageErrorf("%s already exists, use -force to overwrite it", existing))
+	}
+
+	t := defaultTarget()
+	t.Output = target
+	for _, pkgPath := range packagePaths {
+		t.Packages = append(t.Packages, Package{Path: pkgPath, Marked: marked})
+	}
+	config := Config{Static: static, Targets: []Target{t}}
+	bytes, err := yaml.Marshal(config)
+	if err != nil {
+		return fail(err)
+	}
+
+	// The directive goes into the first package, run from its directory
+	dir, err := packageDir(packagePaths[0], "")
+	if err != nil {
+		return fail(err)
+	}
+	pkgName, err := packageName(packagePaths[0])
+	if err != nil {
+		return fail(err)
+	}
+	generate := filepath.Join(dir, generateFile)
+	if _, err := os.Stat(generate); err == nil && !force {
+		return fail(usageErrorf("%s already exists, use -force to overwrite it", generate))
+	}
+	configPath, err := filepath.Abs(configFile)
+	if err != nil {
+		return fail(err)
+	}
+	if configPath, err = filepath.Rel(dir, configPath); err != nil {
+		return fail(err)
+	}
+
+	if err := ioutil.WriteFile(configFile, bytes, 0644); err != nil {
+		return fail(err)
+	}
+	source := fmt.Sprintf("package %s\n\n//go:generate tscriptify gen -config=%s\n", pkgName, filepath.ToSlash(configPath))
+	if err := ioutil.WriteFile(generate, []byte(source), 0644); err != nil {
+		return fail(err)
+	}
+
+	fmt.Println("Created", configFile, "and", generate)
+	fmt.Println("Run \"go generate\" in the package or \"tscriptify gen\" here")
+	return exitOK
+}
+
+// packageName returns the name declared by a package.
+func packageName(pkgPath string) (string, error) {
+	output, err := exec.Command("go", "list", "-f", "{{.Name}}", pkgPath).Output()
+	if err != nil {
+		return "", fmt.Errorf("Cannot find package %s: %s", pkgPath, err.Error())
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// restore lists the backups of a target file, or restores the one given by
+// its number in the list or by its path.
+func restore(args []string) int {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	var target, backupExtension, backupDir string
+	fs.StringVar(&target, "target", "", "Target typescript file")
+	fs.StringVar(&backupExtension, "extension", "", "Extension of backup files")
+	fs.StringVar(&backupDir, "backup", "", "Directory where backup files are saved")
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+
+	if len(target) == 0 {
+		return fail(usageErrorf("No target file"))
+	}
+
+	converter := typescriptify.New()
+	converter.BackupDir = backupDir
+	if len(backupExtension) > 0 {
+		converter.BackupExtension = backupExtension
+	}
+
+	backups, err := converter.Backups(target)
+	if err != nil {
+		return fail(err)
+	}
+
+	if fs.NArg() == 0 {
+		if len(backups) == 0 {
+			fmt.Println("No backups of", target)
+		}
+		for n, b := range backups {
+			fmt.Printf("%3d  %s  %s\n", n+1, b.Time.Format("2006-01-02 15:04:05"), b.Path)
+		}
+		return exitOK
+	}
+
+	chosen := fs.Arg(0)
+	var backup *typescriptify.Backup
+	for n, b := range backups {
+		if chosen == strconv.Itoa(n+1) || chosen == b.Path {
+			backup = &backups[n]
+			break
+		}
+	}
+	if backup == nil {
+		return fail(usageErrorf("No such backup: %s", chosen))
+	}
+
+	if err := converter.RestoreBackup(target, *backup); err != nil {
+		return fail(err)
+	}
+	fmt.Println("Restored", target, "from", backup.Path)
+	return exitOK
+}
+
+// fail prints an error and returns the exit code for it.
+func fail(err error) int {
+	fmt.Fprintln(os.Stderr, err.Error())
+	if errors.As(err, new(usageError)) {
+		return exitUsage
+	}
+	return exitFailed
+}
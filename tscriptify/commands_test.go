@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestUsageExitCodes(t *testing.T) {
+	for _, args := range [][]string{
+		{"gen", "-unknown"},
+		{"gen", "-package=.", "Person"},
+		{"check", "-package=.", "-include=("},
+		{"gen", "-check", "-watch", "-target=models.ts"},
+		{"list", "-format=xml"},
+		{"restore"},
+	} {
+		if code := run(args); code != exitUsage {
+			t.Errorf("%v: expected exit code %d, got %d", args, exitUsage, code)
+		}
+	}
+
+	if code := run([]string{"help"}); code != exitOK {
+		t.Errorf("help: expected exit code %d, got %d", exitOK, code)
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/amanbolat/go-tscriptify/typescriptify"
+	"gopkg.in/yaml.v2"
+)
+
+// configFiles are looked up in the current directory when neither -config
+// nor -package is given.
+var configFiles = []string{"tscriptify.yaml", "tscriptify.yml", "tscriptify.json"}
+
+// Config describes everything generated by one tscriptify run. It is read
+// from a YAML or JSON file, paths in it are relative to that file.
+type Config struct {
+	Static  bool     `yaml:"static,omitempty"`
+	Tags    string   `yaml:"tags,omitempty"`
+	NoCache bool     `yaml:"no_cache,omitempty"` // Don't cache compiled helper programs
+	Targets []Target `yaml:"targets"`
+}
+
+// Target is one generated TypeScript file.
+type Target struct {
+	Output           string    `yaml:"output"`
+	Packages         []Package `yaml:"packages"`
+	Prefix           string    `yaml:"prefix,omitempty"`
+	Suffix           string    `yaml:"suffix,omitempty"`
+	Indent           string    `yaml:"indent,omitempty"`
+	CreateFromMethod bool      `yaml:"create_from_method"`
+	ExportClass      bool      `yaml:"export_class"`
+	Interface        bool      `yaml:"interface"`
+	Mappings         []string  `yaml:"mappings,omitempty"`         // Mapping packs, see typescriptify.MappingPacks
+	RawMessageType   string    `yaml:"raw_message_type,omitempty"` // "unknown" if empty
+	Base64Type       bool      `yaml:"base64_type,omitempty"`
+	Int64Type        string    `yaml:"int64_type,omitempty"` // number, string or bigint
+	Date             Date      `yaml:"date,omitempty"`
+	Strict           bool      `yaml:"strict,omitempty"`
+	Aliases          bool      `yaml:"aliases,omitempty"`          // Declare named slices, maps and basic types
+	BrandAliases     bool      `yaml:"brand_aliases,omitempty"`    // Brand the aliases of basic types
+	SkipUnsupported  bool      `yaml:"skip_unsupported,omitempty"` // Skip channels, functions and complex numbers with a warning
+	TypeScript       string    `yaml:"typescript,omitempty"`       // Oldest TypeScript version used, e.g. "4.9"
+	Backup           Backup    `yaml:"backup"`
+}
+
+// Package selects the types converted from one Go package. Without Types,
+// all convertible types of the package matching Include and not matching
+// Exclude are used.
+type Package struct {
+	Path    string                 `yaml:"path"`
+	Types   []string               `yaml:"types,omitempty"`
+	Include string                 `yaml:"include,omitempty"`
+	Exclude string                 `yaml:"exclude,omitempty"`
+	Marked  bool                   `yaml:"marked,omitempty"` // Discover only types marked with //tscriptify:export
+	Options map[string]TypeOptions `yaml:"options,omitempty"`
+}
+
+// TypeOptions override the target options for one type.
+type TypeOptions struct {
+	Name      string `yaml:"name,omitempty" json:"name,omitempty"`
+	Interface bool   `yaml:"interface,omitempty" json:"interface,omitempty"`
+	Class     bool   `yaml:"class,omitempty" json:"class,omitempty"`
+}
+
+func (o TypeOptions) validate() error {
+	if o.Interface && o.Class {
+		return errors.New("a type can't be both interface and class")
+	}
+	return nil
+}
+
+func (o TypeOptions) library() typescriptify.TypeOptions {
+	return typescriptify.TypeOptions{Name: o.Name, Interface: o.Interface, Class: o.Class}
+}
+
+// addDiscovered adds discovered types to the package, only the marked ones
+// if Marked is set. Options from the config win over those of the markers.
+func (p *Package) addDiscovered(found []DiscoveredType) {
+	for _, d := range found {
+		if p.Marked && !d.Marked {
+			continue
+		}
+		p.Types = append(p.Types, d.Name)
+		if _, ok := p.Options[d.Name]; !ok && d.Options != (TypeOptions{}) {
+			if p.Options == nil {
+				p.Options = make(map[string]TypeOptions)
+			}
+			p.Options[d.Name] = d.Options
+		}
+	}
+}
+
+func (p Package) filter() (TypeFilter, error) {
+	return newTypeFilter(p.Include, p.Exclude)
+}
+
+func newTypeFilter(include, exclude string) (TypeFilter, error) {
+	var filter TypeFilter
+	var err error
+	if len(include) > 0 {
+		if filter.Include, err = regexp.Compile(include); err != nil {
+			return filter, err
+		}
+	}
+	if len(exclude) > 0 {
+		if filter.Exclude, err = regexp.Compile(exclude); err != nil {
+			return filter, err
+		}
+	}
+	return filter, nil
+}
+
+// Date selects the TypeScript type of dates, see typescriptify.DateType.
+// Type is string, ISODateString or Date (default), any other type needs the
+// expressions converting it.
+type Date struct {
+	Type   string `yaml:"type,omitempty"`
+	Import string `yaml:"import,omitempty"`
+	Parse  string `yaml:"parse,omitempty"`
+	Format string `yaml:"format,omitempty"`
+}
+
+// DateType is used by TEMPLATE.
+func (d Date) DateType() typescriptify.DateType {
+	if d == (Date{Type: d.Type}) {
+		switch d.Type {
+		case "", "Date":
+			return typescriptify.DateObject
+		case "string":
+			return typescriptify.DateString
+		case "ISODateString":
+			return typescriptify.DateISOString
+		}
+	}
+	return typescriptify.DateType{Type: d.Type, Import: d.Import, Parse: d.Parse, Format: d.Format}
+}
+
+type Backup struct {
+	Extension string        `yaml:"extension"`
+	Dir       string        `yaml:"dir,omitempty"`
+	Keep      int           `yaml:"keep,omitempty"`
+	MaxAge    time.Duration `yaml:"max_age,omitempty"`
+}
+
+func defaultTarget() Target {
+	return Target{
+		CreateFromMethod: true,
+		ExportClass:      true,
+		Interface:        true,
+		Backup:           Backup{Extension: "backup"},
+	}
+}
+
+// UnmarshalYAML fills in the defaults for options missing in the file.
+func (t *Target) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain Target
+	*t = defaultTarget()
+	return unmarshal((*plain)(t))
+}
+
+func loadConfig(fileName string) (*Config, error) {
+	bytes, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	config := new(Config)
+	if err := yaml.UnmarshalStrict(bytes, config); err != nil {
+		return nil, fmt.Errorf("Invalid config %s: %s", fileName, err.Error())
+	}
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("Invalid config %s: %s", fileName, err.Error())
+	}
+
+	return config, nil
+}
+
+// findConfig returns the first config file present in the current directory.
+func findConfig() string {
+	for _, name := range configFiles {
+		if _, err := os.Stat(name); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+func (c Config) validate() error {
+	if len(c.Targets) == 0 {
+		return errors.New("no targets")
+	}
+	for n, target := range c.Targets {
+		if len(target.Output) == 0 {
+			return fmt.Errorf("target %d has no output", n+1)
+		}
+		if len(target.Packages) == 0 {
+			return fmt.Errorf("target %s has no packages", target.Output)
+		}
+		if err := validateMappings(target.Mappings); err != nil {
+			return fmt.Errorf("target %s: %s", target.Output, err.Error())
+		}
+		if err := validateInt64Type(target.Int64Type); err != nil {
+			return fmt.Errorf("target %s: %s", target.Output, err.Error())
+		}
+		if len(target.Date.Type) == 0 && target.Date != (Date{}) {
+			return fmt.Errorf("target %s: date has no type", target.Output)
+		}
+		for _, pkg := range target.Packages {
+			if len(pkg.Path) == 0 {
+				return fmt.Errorf("target %s has a package without path", target.Output)
+			}
+			if _, err := pkg.filter(); err != nil {
+				return fmt.Errorf("package %s: %s", pkg.Path, err.Error())
+			}
+			for name, options := range pkg.Options {
+				if err := options.validate(); err != nil {
+					return fmt.Errorf("type %s: %s", name, err.Error())
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// discoverTypes fills in the types of the packages listing none.
+func (c *Config) discoverTypes() error {
+	for i := range c.Targets {
+		for j := range c.Targets[i].Packages {
+			pkg := &c.Targets[i].Packages[j]
+			if len(pkg.Types) > 0 {
+				continue
+			}
+
+			filter, err := pkg.filter()
+			if err != nil {
+				return err
+			}
+			dir, err := packageDir(pkg.Path, c.Tags)
+			if err != nil {
+				return err
+			}
+			found, err := GetGolangTypes(dir, filter)
+			if err != nil {
+				return err
+			}
+			pkg.addDiscovered(found)
+			if len(pkg.Types) == 0 && pkg.Marked {
+				return fmt.Errorf("No types marked with %s found in package %s", exportMarker, pkg.Path)
+			}
+			if len(pkg.Types) == 0 {
+				return fmt.Errorf("No types found in package %s", pkg.Path)
+			}
+		}
+	}
+	return nil
+}
+
+func validateMappings(packs []string) error {
+	return typescriptify.New().UseMappings(packs...)
+}
+
+func validateInt64Type(int64Type string) error {
+	switch int64Type {
+	case "", typescriptify.Int64Number, typescriptify.Int64String, typescriptify.Int64BigInt:
+		return nil
+	}
+	return fmt.Errorf("unknown int64 type %s, use number, string or bigint", int64Type)
+}
+
+func (c Config) buildTags() []string {
+	if len(c.Tags) == 0 {
+		return nil
+	}
+	return strings.Split(c.Tags, ",")
+}
+
+// converter returns a converter with the options of the target.
+func (t Target) converter() *typescriptify.TypeScriptify {
+	converter := typescriptify.New()
+	converter.Prefix = t.Prefix
+	converter.Suffix = t.Suffix
+	if len(t.Indent) > 0 {
+		converter.Indent = t.Indent
+	}
+	converter.CreateFromMethod = t.CreateFromMethod
+	converter.DoExportClass = t.ExportClass
+	converter.UseInterface = t.Interface
+	// Validated with the config
+	converter.UseMappings(t.Mappings...)
+	if len(t.RawMessageType) > 0 {
+		converter.RawMessageType = t.RawMessageType
+	}
+	converter.Base64Type = t.Base64Type
+	converter.Int64Type = t.Int64Type
+	converter.DateType = t.Date.DateType()
+	converter.Strict = t.Strict
+	converter.DeclareAliases = t.Aliases
+	converter.BrandAliases = t.BrandAliases
+	converter.SkipUnsupported = t.SkipUnsupported
+	converter.TypeScriptVersion = t.TypeScript
+	converter.BackupExtension = t.Backup.Extension
+	converter.BackupDir = t.Backup.Dir
+	converter.BackupKeep = t.Backup.Keep
+	converter.BackupMaxAge = t.Backup.MaxAge
+	return converter
+}
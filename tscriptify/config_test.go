@@ -0,0 +1,144 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/amanbolat/go-tscriptify/typescriptify"
+)
+
+const yamlConfig = `static: true
+tags: integration
+targets:
+  - output: web/models.ts
+    packages:
+      - path: ./models
+        types: [Person, Address]
+        options:
+          Person:
+            name: User
+            class: true
+  - output: web/admin.ts
+    packages:
+      - path: ./admin
+        include: ^Admin
+    interface: false
+    export_class: false
+    mappings: [uuid]
+    int64_type: bigint
+    date:
+      type: DateTime
+      import: import { DateTime } from "luxon";
+      parse: DateTime.fromISO(value)
+    backup:
+      extension: bak
+      keep: 3
+`
+
+const jsonConfig = `{
+  "targets": [
+    {
+      "output": "models.ts",
+      "packages": [{"path": "./models", "marked": true}],
+      "strict": true,
+      "typescript": "4.9"
+    }
+  ]
+}`
+
+// writeConfig writes a config file to a temporary directory.
+func writeConfig(t *testing.T, name, content string) string {
+	dir, err := ioutil.TempDir("", "tscriptify")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	fileName := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(fileName, []byte(content), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+	return fileName
+}
+
+func TestLoadConfig(t *testing.T) {
+	config, err := loadConfig(writeConfig(t, "tscriptify.yaml", yamlConfig))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !config.Static || config.Tags != "integration" || len(config.Targets) != 2 {
+		t.Fatalf("unexpected config %+v", config)
+	}
+
+	// Defaults of missing options
+	models := config.Targets[0]
+	if !models.CreateFromMethod || !models.ExportClass || !models.Interface || models.Backup.Extension != "backup" {
+		t.Errorf("defaults not set: %+v", models)
+	}
+	if options := models.Packages[0].Options["Person"]; options != (TypeOptions{Name: "User", Class: true}) {
+		t.Errorf("unexpected options %+v", options)
+	}
+
+	admin := config.Targets[1]
+	if admin.Interface || admin.ExportClass || !admin.CreateFromMethod {
+		t.Errorf("options not overridden: %+v", admin)
+	}
+	if admin.Int64Type != typescriptify.Int64BigInt || len(admin.Mappings) != 1 || admin.Backup != (Backup{Extension: "bak", Keep: 3}) {
+		t.Errorf("unexpected target %+v", admin)
+	}
+	if dateType := admin.Date.DateType(); dateType.Type != "DateTime" || dateType.Parse != "DateTime.fromISO(value)" || len(dateType.Import) == 0 {
+		t.Errorf("unexpected date type %+v", dateType)
+	}
+
+	config, err = loadConfig(writeConfig(t, "tscriptify.json", jsonConfig))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	target := config.Targets[0]
+	if !target.Packages[0].Marked || !target.Strict || target.TypeScript != "4.9" || !target.Interface {
+		t.Errorf("unexpected target %+v", target)
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	for _, test := range []struct {
+		config string
+		err    string
+	}{
+		{"targets: []", "no targets"},
+		{"targets:\n  - packages: [{path: ./models}]", "target 1 has no output"},
+		{"targets:\n  - output: models.ts", "target models.ts has no packages"},
+		{"targets:\n  - output: models.ts\n    packages: [{types: [Person]}]", "has a package without path"},
+		{"targets:\n  - output: models.ts\n    packages: [{path: ./models, include: \"(\"}]", "package ./models:"},
+		{"targets:\n  - output: models.ts\n    packages: [{path: ./models, options: {Person: {interface: true, class: true}}}]", "type Person: a type can't be both interface and class"},
+		{"targets:\n  - output: models.ts\n    packages: [{path: ./models}]\n    mappings: [unknown]", "Unknown mapping pack unknown"},
+		{"targets:\n  - output: models.ts\n    packages: [{path: ./models}]\n    int64_type: float", "unknown int64 type float"},
+		{"targets:\n  - output: models.ts\n    packages: [{path: ./models}]\n    date: {parse: parse(value)}", "date has no type"},
+		{"targets:\n  - output: models.ts\n    packages: [{path: ./models}]\n    interfaces: true", "field interfaces not found"},
+	} {
+		_, err := loadConfig(writeConfig(t, "tscriptify.yaml", test.config))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: expected error %q, got %v", test.config, test.err, err)
+		}
+	}
+}
+
+func TestDateType(t *testing.T) {
+	for _, test := range []struct {
+		date     Date
+		expected typescriptify.DateType
+	}{
+		{Date{}, typescriptify.DateObject},
+		{Date{Type: "Date"}, typescriptify.DateObject},
+		{Date{Type: "string"}, typescriptify.DateString},
+		{Date{Type: "ISODateString"}, typescriptify.DateISOString},
+		{Date{Type: "Date", Format: "value.toJSON()"}, typescriptify.DateType{Type: "Date", Format: "value.toJSON()"}},
+		{Date{Type: "Dayjs", Parse: "dayjs(value)"}, typescriptify.DateType{Type: "Dayjs", Parse: "dayjs(value)"}},
+	} {
+		if dateType := test.date.DateType(); dateType != test.expected {
+			t.Errorf("%+v: expected %+v, got %+v", test.date, test.expected, dateType)
+		}
+	}
+}
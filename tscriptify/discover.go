@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// TypeFilter selects discovered types by name. Nil expressions match all.
+type TypeFilter struct {
+	Include *regexp.Regexp
+	Exclude *regexp.Regexp
+}
+
+func (f TypeFilter) match(name string) bool {
+	if f.Include != nil && !f.Include.MatchString(name) {
+		return false
+	}
+	if f.Exclude != nil && f.Exclude.MatchString(name) {
+		return false
+	}
+	return true
+}
+
+// exportMarker marks types for conversion. It may be followed by options:
+// "name=TSName", "interface" or "class".
+const exportMarker = "//tscriptify:export"
+
+// DiscoveredType is a type found in Go source files.
+type DiscoveredType struct {
+	Name    string
+	Marked  bool        // Has an export marker comment
+	Options TypeOptions // Options given in the marker
+}
+
+// GetGolangFileStructs returns the exported types declared in a Go file which
+// can be converted: structs and named basic, slice, map and array types.
+func GetGolangFileStructs(filename string) ([]string, error) {
+	found, err := parseGolangFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(found))
+	for _, d := range found {
+		names = append(names, d.Name)
+	}
+	return names, nil
+}
+
+func parseGolangFile(filename string) ([]DiscoveredType, error) {
+	fset := token.NewFileSet() // positions are relative to fset
+
+	f, err := parser.ParseFile(fset, filename, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := fileTypes(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err.Error())
+	}
+	return found, nil
+}
+
+// GetGolangTypes returns the convertible types declared in the Go files
+// given by path: a file, a package directory or a glob matching any of them.
+// Test files are skipped, and in directories build constraints are honored.
+func GetGolangTypes(path string, filter TypeFilter) ([]DiscoveredType, error) {
+	paths := []string{path}
+	if strings.ContainsAny(path, "*?[") {
+		var err error
+		paths, err = filepath.Glob(path)
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(paths)
+	}
+
+	result := make([]DiscoveredType, 0)
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		files, err := goFiles(p)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			found, err := parseGolangFile(file)
+			if err != nil {
+				return nil, err
+			}
+			for _, d := range found {
+				if !seen[d.Name] && filter.match(d.Name) {
+					seen[d.Name] = true
+					result = append(result, d)
+				}
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func goFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		if filepath.Ext(path) != ".go" || strings.HasSuffix(path, "_test.go") {
+			return nil, nil
+		}
+		return []string{path}, nil
+	}
+
+	pkg, err := build.ImportDir(path, 0)
+	if err != nil {
+		if _, ok := err.(*build.NoGoError); ok {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	files := make([]string, 0, len(pkg.GoFiles))
+	for _, name := range pkg.GoFiles {
+		files = append(files, filepath.Join(path, name))
+	}
+	return files, nil
+}
+
+// fileTypes lists the convertible types of a file in declaration order. Type
+// aliases and generic types are skipped, the latter can only be converted
+// once instantiated.
+func fileTypes(f *ast.File) ([]DiscoveredType, error) {
+	result := make([]DiscoveredType, 0)
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if !ts.Name.IsExported() || ts.Assign.IsValid() || ts.TypeParams != nil || !convertible(ts.Type) {
+				continue
+			}
+
+			d := DiscoveredType{Name: ts.Name.Name}
+			doc := ts.Doc
+			if doc == nil && len(gen.Specs) == 1 {
+				doc = gen.Doc
+			}
+			if doc != nil {
+				var err error
+				d.Marked, d.Options, err = parseMarker(doc)
+				if err != nil {
+					return nil, fmt.Errorf("type %s: %s", d.Name, err.Error())
+				}
+			}
+			result = append(result, d)
+		}
+	}
+	return result, nil
+}
+
+func parseMarker(doc *ast.CommentGroup) (bool, TypeOptions, error) {
+	var options TypeOptions
+	for _, c := range doc.List {
+		if !strings.HasPrefix(c.Text, exportMarker) {
+			continue
+		}
+		rest := strings.TrimPrefix(c.Text, exportMarker)
+		if len(rest) > 0 && rest[0] != ' ' && rest[0] != '\t' {
+			continue
+		}
+
+		for _, option := range strings.Fields(rest) {
+			switch {
+			case strings.HasPrefix(option, "name="):
+				options.Name = strings.TrimPrefix(option, "name=")
+			case option == "interface":
+				options.Interface = true
+			case option == "class":
+				options.Class = true
+			default:
+				return false, options, fmt.Errorf("unknown %s option %q", exportMarker, option)
+			}
+		}
+		return true, options, options.validate()
+	}
+	return false, options, nil
+}
+
+func convertible(expr ast.Expr) bool {
+	switch t := expr.(type) {
+	case *ast.StructType, *ast.ArrayType, *ast.MapType:
+		return true
+	case *ast.Ident, *ast.SelectorExpr:
+		// Named basic types like enums, or types based on other named types
+		return true
+	case *ast.ParenExpr:
+		return convertible(t.X)
+	}
+	// Interfaces, functions, channels and pointers
+	return false
+}
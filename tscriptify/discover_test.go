@@ -0,0 +1,81 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+const discoverSource = `package models
+
+type Person struct {
+	Address struct {
+		City string
+	}
+	Friend Friend
+}
+
+type (
+	//tscriptify:export
+	Status int
+	Tags   []string
+	Labels map[string]string
+	// Friend has options.
+	//tscriptify:export name=Buddy interface
+	Friend struct{}
+)
+
+type Page[T any] struct {
+	Items []T
+}
+
+type Alias = Person
+type Handler func()
+type Store interface{}
+type internal struct{}
+`
+
+func TestFileTypes(t *testing.T) {
+	f, err := parser.ParseFile(token.NewFileSet(), "models.go", discoverSource, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	found, err := fileTypes(f)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := []DiscoveredType{
+		{Name: "Person"},
+		{Name: "Status", Marked: true},
+		{Name: "Tags"},
+		{Name: "Labels"},
+		{Name: "Friend", Marked: true, Options: TypeOptions{Name: "Buddy", Interface: true}},
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("Expected %v, got %v", expected, found)
+	}
+}
+
+func TestMarkerOptions(t *testing.T) {
+	src := "package models\n\n//tscriptify:export interface class\ntype A struct{}\n"
+	f, err := parser.ParseFile(token.NewFileSet(), "models.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := fileTypes(f); err == nil {
+		t.Error("expected an error for conflicting options")
+	}
+}
+
+func TestTypeFilter(t *testing.T) {
+	filter := TypeFilter{Include: regexp.MustCompile("^P|^S"), Exclude: regexp.MustCompile("Status")}
+	for name, expected := range map[string]bool{"Person": true, "Status": false, "Store": true, "Tags": false} {
+		if filter.match(name) != expected {
+			t.Errorf("%s: expected %v", name, expected)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/build"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// runHelper generates the helper program converting the models, runs it and
+// returns its exit code. Compiled helpers are cached, see helperCache.
+func runHelper(config *Config, mode string, report bool) int {
+	params, err := helperParams(config, mode)
+	if err != nil {
+		return fail(err)
+	}
+	params.Report = report
+
+	var source bytes.Buffer
+	if err := template.Must(template.New("").Parse(TEMPLATE)).Execute(&source, params); err != nil {
+		return fail(err)
+	}
+
+	var cache *helperCache
+	if !config.NoCache {
+		if cache, err = openHelperCache(); err != nil {
+			fmt.Fprintln(os.Stderr, "Not caching the helper program:", err.Error())
+		}
+	}
+
+	var binary, key string
+	if cache != nil {
+		key, err = cache.key(source.Bytes(), params, config.Tags)
+		if err != nil {
+			return fail(err)
+		}
+		// Writing to stdout or printing a report needs the helper to run
+		if mode == modeGen && !report && cache.upToDate(key, config) {
+			fmt.Println("Up to date")
+			return exitOK
+		}
+		binary = cache.binary(key)
+	} else {
+		dir, err := ioutil.TempDir("", "tscriptify-")
+		if err != nil {
+			return fail(err)
+		}
+		defer os.RemoveAll(dir)
+		binary = filepath.Join(dir, executableName(helperBinary))
+	}
+
+	if _, err := os.Stat(binary); err != nil {
+		if err := buildHelper(source.Bytes(), binary, params.Imports[0].Path, config.Tags); err != nil {
+			return fail(err)
+		}
+	}
+	if cache != nil {
+		cache.touch(key)
+	}
+
+	cmd := exec.Command(binary)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr.ExitCode()
+		}
+		return fail(err)
+	}
+
+	if cache != nil && mode == modeGen {
+		cache.written(key, config)
+	}
+	return exitOK
+}
+
+const helperBinary = "tscriptify-helper"
+
+// buildHelper compiles the helper program into binary. The source is written
+// to a directory removed afterwards, even when interrupted.
+func buildHelper(source []byte, binary, pkgPath, tags string) error {
+	dir, err := helperDir(pkgPath, tags)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	// Don't leave the helper behind when interrupted
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt)
+	defer signal.Stop(interrupted)
+	go func() {
+		if _, ok := <-interrupted; ok {
+			os.RemoveAll(dir)
+			os.Exit(130)
+		}
+	}()
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.go"), source, 0644); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(binary), 0755); err != nil {
+		return err
+	}
+	// Built next to the binary and moved, so a concurrent run never sees a
+	// partially written one
+	partial := fmt.Sprintf("%s.%d", binary, os.Getpid())
+	defer os.Remove(partial)
+
+	args := []string{"build", "-o", partial}
+	if len(tags) > 0 {
+		args = append(args, "-tags", tags)
+	}
+	args = append(args, ".")
+
+	// GOFLAGS and the rest of the environment are passed on unchanged
+	build := exec.Command("go", args...)
+	build.Dir = dir
+	if output, err := build.CombinedOutput(); err != nil {
+		os.Stderr.Write(output)
+		return fmt.Errorf("Cannot build the helper program in %s: %s", dir, err.Error())
+	}
+	return os.Rename(partial, binary)
+}
+
+// helperParams prepares the template input. The helper runs in another
+// directory, so file paths are made absolute and relative package paths are
+// replaced by import paths.
+func helperParams(config *Config, mode string) (Params, error) {
+	params := Params{Mode: mode}
+	aliases := make(map[string]string)
+	used := make(map[string]bool)
+
+	for _, target := range config.Targets {
+		var err error
+		if target.Output != stdoutTarget {
+			target.Output, err = filepath.Abs(target.Output)
+			if err != nil {
+				return params, err
+			}
+		}
+		if len(target.Backup.Dir) > 0 {
+			target.Backup.Dir, err = filepath.Abs(target.Backup.Dir)
+			if err != nil {
+				return params, err
+			}
+		}
+
+		types := make([]TypeParams, 0)
+		for _, pkg := range target.Packages {
+			importPath, err := resolveImportPath(pkg.Path, config.Tags)
+			if err != nil {
+				return params, err
+			}
+
+			alias, found := aliases[importPath]
+			if !found {
+				alias = importAlias(importPath, used)
+				aliases[importPath] = alias
+				used[alias] = true
+				params.Imports = append(params.Imports, Import{Alias: alias, Path: importPath})
+			}
+
+			for _, name := range pkg.Types {
+				types = append(types, TypeParams{Expr: alias + "." + name, Options: pkg.Options[name]})
+			}
+		}
+
+		params.Targets = append(params.Targets, TargetParams{Target: target, Types: types})
+	}
+
+	return params, nil
+}
+
+// Names the helper program uses itself, an import alias must not shadow them:
+// the package, its imports, the variables declared by TEMPLATE and the
+// predeclared identifiers it uses.
+var reservedAliases = map[string]bool{
+	"main": true, "json": true, "fmt": true, "os": true, "reflect": true, "typescriptify": true,
+	"code": true, "reports": true, "t": true, "err": true, "diff": true, "warning": true, "bytes": true,
+	"make": true, "len": true, "append": true, "string": true, "nil": true,
+}
+
+// importAlias returns a unique identifier for importing a package in the
+// helper program. It is the last element of the import path, skipping a major
+// version suffix, and numbered if packages like api/models and billing/models
+// would otherwise clash.
+func importAlias(importPath string, used map[string]bool) string {
+	elements := strings.Split(importPath, "/")
+	base := elements[len(elements)-1]
+	if len(elements) > 1 && majorVersion.MatchString(base) {
+		base = elements[len(elements)-2]
+	}
+
+	base = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return r
+		}
+		return '_'
+	}, base)
+	if len(base) == 0 || unicode.IsDigit(rune(base[0])) || token.IsKeyword(base) {
+		base = "pkg_" + base
+	}
+
+	alias := base
+	for n := 2; used[alias] || reservedAliases[alias]; n++ {
+		alias = base + strconv.Itoa(n)
+	}
+	return alias
+}
+
+var majorVersion = regexp.MustCompile(`^v[0-9]+$`)
+
+// resolveImportPath returns the import path of a package given by a relative
+// directory like "./models". Other paths are returned unchanged.
+func resolveImportPath(pkgPath, tags string) (string, error) {
+	if !build.IsLocalImport(pkgPath) {
+		return pkgPath, nil
+	}
+
+	args := []string{"list", "-f", "{{.ImportPath}}"}
+	if len(tags) > 0 {
+		args = append(args, "-tags", tags)
+	}
+	args = append(args, pkgPath)
+
+	output, err := exec.Command("go", args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("Cannot resolve package %s: %s", pkgPath, err.Error())
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// helperDir creates the directory for the helper program. If possible it is
+// a hidden directory inside the models package, so the helper is built within
+// the user's module: go.mod with its replace directives, go.work and vendor
+// are honored, and internal packages can be imported. Otherwise a system
+// temporary directory is used.
+func helperDir(packagePath, tags string) (string, error) {
+	if pkgDir, err := packageDir(packagePath, tags); err == nil {
+		// Go tools ignore directories starting with ".", so a left over
+		// helper doesn't break "go build ./..."
+		if dir, err := ioutil.TempDir(pkgDir, ".tscriptify-"); err == nil {
+			return dir, nil
+		}
+	}
+
+	return ioutil.TempDir("", "tscriptify-")
+}
+
+// packageDir returns the source directory of a package.
+func packageDir(pkgPath, tags string) (string, error) {
+	args := []string{"list", "-f", "{{.Dir}}"}
+	if len(tags) > 0 {
+		args = append(args, "-tags", tags)
+	}
+	args = append(args, pkgPath)
+
+	output, err := exec.Command("go", args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("Cannot find directory of package %s: %s", pkgPath, err.Error())
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// packageDeps returns the directories of a package and of the packages it
+// imports, directly or not, which can change: the standard library and
+// modules downloaded to the module cache are left out.
+func packageDeps(pkgPath, tags string) ([]string, error) {
+	args := []string{"list", "-deps", "-f", "{{ if and (not .Standard) (or (not .Module) .Module.Main .Module.Replace) }}{{ .Dir }}{{ end }}"}
+	if len(tags) > 0 {
+		args = append(args, "-tags", tags)
+	}
+	args = append(args, pkgPath)
+
+	output, err := exec.Command("go", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("Cannot list dependencies of package %s: %s", pkgPath, err.Error())
+	}
+	return strings.Fields(string(output)), nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import "testing"
+
+func TestImportAlias(t *testing.T) {
+	used := make(map[string]bool)
+	for _, test := range []struct {
+		importPath string
+		alias      string
+	}{
+		{"github.com/you/project/api", "api"},
+		{"github.com/you/project/billing/models", "models"},
+		{"github.com/you/project/shipping/models", "models2"},
+		{"github.com/you/project/v2", "project"},
+		{"github.com/you/go-models", "go_models"},
+		{"github.com/you/fmt", "fmt2"},
+		{"github.com/you/type", "pkg_type"},
+		{"github.com/you/project/code", "code2"},
+		{"github.com/you/project/json", "json2"},
+		{"github.com/you/project/reports", "reports2"},
+	} {
+		alias := importAlias(test.importPath, used)
+		used[alias] = true
+		if alias != test.alias {
+			t.Errorf("%s: expected alias %s, got %s", test.importPath, test.alias, alias)
+		}
+	}
+}
+
+func TestQualifiedPackage(t *testing.T) {
+	packages := []Package{{Path: "./api"}, {Path: "github.com/you/project/billing"}, {Path: "github.com/you/other/billing"}}
+
+	if qualifier, name := splitQualified("Invoice"); qualifier != "" || name != "Invoice" {
+		t.Errorf("unexpected %q %q", qualifier, name)
+	}
+	if qualifier, name := splitQualified("github.com/you/project/billing.Invoice"); qualifier != "github.com/you/project/billing" || name != "Invoice" {
+		t.Errorf("unexpected %q %q", qualifier, name)
+	}
+
+	if n, err := qualifiedPackage(packages, "api"); err != nil || n != 0 {
+		t.Errorf("api: got %d, %v", n, err)
+	}
+	if n, err := qualifiedPackage(packages, "github.com/you/other/billing"); err != nil || n != 2 {
+		t.Errorf("other billing: got %d, %v", n, err)
+	}
+	if _, err := qualifiedPackage(packages, "billing"); err == nil {
+		t.Error("expected billing to be ambiguous")
+	}
+	if _, err := qualifiedPackage(packages, "shipping"); err == nil {
+		t.Error("expected an error for a missing package")
+	}
+	if n, err := qualifiedPackage(packages, "./shipping"); err != nil || n != -1 {
+		t.Errorf("./shipping: got %d, %v", n, err)
+	}
+}
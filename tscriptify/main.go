@@ -1,159 +1,478 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"os"
-	"os/exec"
+	"path"
+	"path/filepath"
 	"strings"
-	"text/template"
 	"time"
-	"go/ast"
-	"go/parser"
-	"go/token"
-	"log"
+
+	"github.com/amanbolat/go-tscriptify/typescriptify"
 )
 
 const TEMPLATE = `package main
 
 import (
-	"fmt"
+{{ if .Report }}	"encoding/json"
+{{ end }}	"fmt"
+	"os"
+	"reflect"
 
-	"{{ .ModelsPackage }}"
-	"github.com/amanbolat/go-tscriptify/typescriptify"
+{{ range .Imports }}	{{ .Alias }} {{ printf "%q" .Path }}
+{{ end }}	"github.com/amanbolat/go-tscriptify/typescriptify"
 )
 
 func main() {
-	t := typescriptify.New()
-	t.UseInterface = {{ .UseInterface }}
-{{ range .Structs }}	t.Add({{ . }}{})
+	// Exit codes as those of tscriptify
+	code := 0
+{{ if .Report }}	reports := make([]typescriptify.Report, 0)
+{{ end }}{{ range .Targets }}
+	{
+		t := typescriptify.New()
+		t.Prefix = {{ printf "%q" .Prefix }}
+		t.Suffix = {{ printf "%q" .Suffix }}
+{{ if .Indent }}		t.Indent = {{ printf "%q" .Indent }}
+{{ end }}		t.CreateFromMethod = {{ .CreateFromMethod }}
+		t.DoExportClass = {{ .ExportClass }}
+		t.UseInterface = {{ .Interface }}
+{{ if .Mappings }}		t.UseMappings({{ range .Mappings }}{{ printf "%q" . }}, {{ end }})
+{{ end }}{{ if .RawMessageType }}		t.RawMessageType = {{ printf "%q" .RawMessageType }}
+{{ end }}		t.Base64Type = {{ .Base64Type }}
+{{ if .Int64Type }}		t.Int64Type = {{ printf "%q" .Int64Type }}
+{{ end }}		t.DateType = {{ printf "%#v" .Date.DateType }}
+		t.Strict = {{ .Strict }}
+		t.DeclareAliases = {{ .Aliases }}
+		t.BrandAliases = {{ .BrandAliases }}
+		t.SkipUnsupported = {{ .SkipUnsupported }}
+		t.TypeScriptVersion = {{ printf "%q" .TypeScript }}
+		t.BackupExtension = {{ printf "%q" .Backup.Extension }}
+		t.BackupDir = {{ printf "%q" .Backup.Dir }}
+		t.BackupKeep = {{ .Backup.Keep }}
+		t.BackupMaxAge = {{ printf "%d" .Backup.MaxAge }}
+{{ range .Types }}		t.AddTypeWithOptions(reflect.TypeOf((*{{ .Expr }})(nil)).Elem(), typescriptify.TypeOptions{Name: {{ printf "%q" .Options.Name }}, Interface: {{ .Options.Interface }}, Class: {{ .Options.Class }}})
 {{ end }}
-	err := t.ConvertToFile("{{ .TargetFile }}")
-	if err != nil {
-		panic(err.Error())
-	}
-	fmt.Println("OK")
+{{ if eq $.Mode "gen" }}{{ if eq .Output "-" }}		err := t.ConvertTo(os.Stdout)
+{{ else }}		err := t.ConvertToFile({{ printf "%q" .Output }})
+{{ end }}		if err != nil {
+			fmt.Fprintln(os.Stderr, {{ printf "%q" .Output }}+":", err.Error())
+			code = 3
+		}
+{{ else }}		diff, err := t.Verify({{ printf "%q" .Output }})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, {{ printf "%q" .Output }}+":", err.Error())
+			code = 3
+		} else if len(diff) > 0 {
+{{ if not $.Report }}			fmt.Print(diff)
+{{ end }}{{ if eq $.Mode "check" }}			fmt.Fprintln(os.Stderr, {{ printf "%q" .Output }}, "is out of date")
+			if code == 0 {
+				code = 1
+			}
+{{ end }}		}
+{{ end }}		for _, warning := range t.Report().Warnings {
+			fmt.Fprintln(os.Stderr, {{ printf "%q" .Output }}+": warning:", warning)
+		}
+{{ if $.Report }}		reports = append(reports, t.Report())
+{{ end }}	}
+{{ end }}
+{{ if .Report }}	bytes, _ := json.MarshalIndent(reports, "", "  ")
+	fmt.Println(string(bytes))
+{{ end }}	os.Exit(code)
 }`
 
+// Params are the input of TEMPLATE
 type Params struct {
-	ModelsPackage string
-	TargetFile    string
-	Structs       []string
-	UseInterface bool
+	Imports []Import
+	Targets []TargetParams
+	Mode    string
+	Report  bool
+}
+
+type Import struct {
+	Alias string
+	Path  string
+}
+
+type TargetParams struct {
+	Target
+	Types []TypeParams
 }
 
+type TypeParams struct {
+	Expr    string // Qualified Go type name
+	Options TypeOptions
+}
+
+// Exit codes
+const (
+	exitOK       = 0
+	exitOutdated = 1 // check found a target which isn't up to date
+	exitUsage    = 2 // invalid command line or config
+	exitFailed   = 3 // loading, converting or writing failed
+)
+
+// stdoutTarget as output writes the generated file to stdout.
+const stdoutTarget = "-"
+
+// Modes of converting the targets
+const (
+	modeGen   = "gen"   // write the targets
+	modeCheck = "check" // print the differences and fail if there are any
+	modeDiff  = "diff"  // only print the differences
+)
+
 func main() {
-	var packagePath, target, stringExtension string
-	var useInterface bool
-	flag.StringVar(&packagePath, "package", "", "Path of the package with models")
-	flag.StringVar(&target, "target", "", "Target typescript file")
-	flag.StringVar(&stringExtension, "extension", "", "")
-	flag.BoolVar(&useInterface, "interface", true, "use interface instead of class")
-	flag.Parse()
-
-	structs := []string{}
-	for _, structOrGoFile := range flag.Args() {
-		if strings.HasSuffix(structOrGoFile, ".go") {
-			fmt.Println("Parsing:", structOrGoFile)
-			fileStructs, err := GetGolangFileStructs(structOrGoFile)
+	os.Exit(run(os.Args[1:]))
+}
+
+// usageError is an error in the command line or the config.
+type usageError struct {
+	error
+}
+
+func usageErrorf(format string, args ...interface{}) error {
+	return usageError{fmt.Errorf(format, args...)}
+}
+
+// options are the flags shared by the commands selecting what is converted.
+type options struct {
+	configFile   string
+	packagePaths stringList
+	target       string
+	include      string
+	exclude      string
+	marked       bool
+	tags         string
+	static       bool
+	noCache      bool
+	useInterface bool
+	mappings     string
+	int64Type    string
+	dateType     string
+	strict       bool
+	aliases      bool
+	brandAliases bool
+	typeScript   string
+	report       string
+
+	skipUnsupported bool
+
+	backupExtension string
+	backupDir       string
+	backupKeep      int
+	backupMaxAge    time.Duration
+}
+
+func (o *options) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.configFile, "config", "", "Config file with the targets to generate (default tscriptify.yaml, .yml or .json if present)")
+	fs.Var(&o.packagePaths, "package", "Path of a package with models, can be repeated (default the package in the current directory)")
+	fs.StringVar(&o.target, "target", "", "Target typescript file, - for stdout")
+	fs.StringVar(&o.include, "include", "", "Only convert discovered types with names matching this regular expression")
+	fs.StringVar(&o.exclude, "exclude", "", "Don't convert discovered types with names matching this regular expression")
+	fs.BoolVar(&o.marked, "marked", false, "Only convert discovered types marked with a //tscriptify:export comment")
+	fs.StringVar(&o.tags, "tags", "", "Build tags used when loading the models package")
+	fs.BoolVar(&o.static, "static", false, "Load the models with go/types instead of compiling and running a helper program")
+	fs.BoolVar(&o.noCache, "no-cache", false, "Don't cache the compiled helper program")
+	fs.BoolVar(&o.useInterface, "interface", true, "use interface instead of class")
+	fs.StringVar(&o.mappings, "mappings", "", "Comma separated mapping packs for common types: "+strings.Join(typescriptify.MappingPacks(), ", "))
+	fs.StringVar(&o.int64Type, "int64", "", "TypeScript type of int64 and uint64: number, string or bigint")
+	fs.StringVar(&o.dateType, "date", "", "TypeScript type of dates: string, ISODateString or Date")
+	fs.BoolVar(&o.strict, "strict", false, "Output for the strict TypeScript options")
+	fs.BoolVar(&o.aliases, "aliases", false, "Declare named slices, maps and basic types as type aliases")
+	fs.BoolVar(&o.brandAliases, "brand-aliases", false, "Brand the type aliases of basic types")
+	fs.BoolVar(&o.skipUnsupported, "skip-unsupported", false, "Skip fields encoding/json can't encode, like channels and functions, with a warning")
+	fs.StringVar(&o.typeScript, "typescript", "", "Oldest TypeScript version the output must compile with, e.g. 4.9")
+}
+
+func (o *options) registerReport(fs *flag.FlagSet) {
+	fs.StringVar(&o.report, "report", "", "Print a report of the generated entities and warnings, the format is json")
+}
+
+func (o *options) validateReport() error {
+	if len(o.report) > 0 && o.report != "json" {
+		return usageErrorf("Unknown report format %s", o.report)
+	}
+	return nil
+}
+
+func (o *options) registerBackup(fs *flag.FlagSet) {
+	fs.StringVar(&o.backupExtension, "extension", "backup", "Extension of backup files, empty disables backups")
+	fs.StringVar(&o.backupDir, "backup", "", "Directory where backup files are saved")
+	fs.IntVar(&o.backupKeep, "backup-keep", 0, "Number of backups to keep (0 keeps all)")
+	fs.DurationVar(&o.backupMaxAge, "backup-max-age", 0, "Remove backups older than this (0 keeps all)")
+}
+
+// config returns the config file given or found, or else a config with a
+// single target built from the flags and the type arguments. Types of the
+// packages are not discovered yet.
+func (o *options) config(args []string) (*Config, error) {
+	configFile := o.configFile
+	if len(configFile) == 0 && len(o.packagePaths) == 0 {
+		configFile = findConfig()
+	}
+
+	if len(configFile) > 0 {
+		if len(args) > 0 {
+			return nil, usageErrorf("Types are selected in the config file %s, not with arguments", configFile)
+		}
+		config, err := loadConfig(configFile)
+		if err != nil {
+			return nil, usageError{err}
+		}
+		// Paths in the config are relative to the config file
+		if err := os.Chdir(filepath.Dir(configFile)); err != nil {
+			return nil, err
+		}
+		if o.static {
+			config.Static = true
+		}
+		if o.noCache {
+			config.NoCache = true
+		}
+		if len(o.tags) > 0 {
+			config.Tags = o.tags
+		}
+		return config, nil
+	}
+
+	filter, err := newTypeFilter(o.include, o.exclude)
+	if err != nil {
+		return nil, usageError{err}
+	}
+	if len(o.target) == 0 {
+		return nil, usageErrorf("No target file")
+	}
+
+	// Packages without types get all their types discovered
+	packages := make([]Package, 0, len(o.packagePaths))
+	for _, pkgPath := range o.packagePaths {
+		packages = append(packages, Package{Path: pkgPath, Include: o.include, Exclude: o.exclude, Marked: o.marked})
+	}
+	// Unqualified types belong to the first package
+	defaultPackage := func() int {
+		if len(packages) == 0 {
+			// The package in the current directory, e.g. when run by go generate
+			packages = append(packages, Package{Path: ".", Include: o.include, Exclude: o.exclude, Marked: o.marked})
+		}
+		return 0
+	}
+
+	for _, arg := range args {
+		arg = strings.TrimSpace(arg)
+		if len(arg) == 0 {
+			continue
+		}
+		if info, err := os.Stat(arg); (err == nil && info.IsDir()) || strings.HasSuffix(arg, ".go") || strings.ContainsAny(arg, "*?[") {
+			found, err := GetGolangTypes(arg, filter)
 			if err != nil {
-				panic(fmt.Sprintf("Error loading/parsing golang file %s: %s", structOrGoFile, err.Error()))
+				return nil, fmt.Errorf("Error loading/parsing golang files %s: %s", arg, err.Error())
 			}
-			for _, s := range fileStructs {
-				structs = append(structs, s)
+			n := defaultPackage()
+			if len(packages) > 1 {
+				if n, err = filesPackage(packages, arg, o.tags); err != nil {
+					return nil, usageError{err}
+				}
 			}
+			packages[n].addDiscovered(found)
 		} else {
-			structs = append(structs, structOrGoFile)
+			qualifier, name := splitQualified(arg)
+			n := 0
+			if len(qualifier) == 0 {
+				n = defaultPackage()
+			} else {
+				if n, err = qualifiedPackage(packages, qualifier); err != nil {
+					return nil, usageError{err}
+				}
+				if n < 0 {
+					// A package given by its path only in the argument
+					packages = append(packages, Package{Path: qualifier})
+					n = len(packages) - 1
+				}
+			}
+			packages[n].Types = append(packages[n].Types, name)
 		}
 	}
+	defaultPackage()
 
-	if len(packagePath) == 0 {
-		fmt.Fprintln(os.Stderr, "No package given")
-		os.Exit(1)
+	t := defaultTarget()
+	t.Output = o.target
+	t.Packages = packages
+	t.Interface = o.useInterface
+	if len(o.mappings) > 0 {
+		t.Mappings = strings.Split(o.mappings, ",")
+		if err := validateMappings(t.Mappings); err != nil {
+			return nil, usageError{err}
+		}
+	}
+	if err := validateInt64Type(o.int64Type); err != nil {
+		return nil, usageError{err}
 	}
-	if len(target) == 0 {
-		fmt.Fprintln(os.Stderr, "No target file")
-		os.Exit(1)
+	t.Int64Type = o.int64Type
+	t.Strict = o.strict
+	t.Aliases = o.aliases
+	t.BrandAliases = o.brandAliases
+	t.SkipUnsupported = o.skipUnsupported
+	t.TypeScript = o.typeScript
+	switch o.dateType {
+	case "", "string", "ISODateString", "Date":
+		t.Date.Type = o.dateType
+	default:
+		return nil, usageErrorf("unknown date type %s, use string, ISODateString or Date", o.dateType)
 	}
+	t.Backup = Backup{Extension: o.backupExtension, Dir: o.backupDir, Keep: o.backupKeep, MaxAge: o.backupMaxAge}
+	return &Config{Static: o.static, Tags: o.tags, NoCache: o.noCache, Targets: []Target{t}}, nil
+}
 
-	packageParts := strings.Split(packagePath, string(os.PathSeparator))
-	pckg := packageParts[len(packageParts)-1]
+// convert converts all targets of the config, discovering their types first.
+// With report the reports of the targets are printed as JSON.
+func convert(config *Config, mode string, report bool) int {
+	for _, target := range config.Targets {
+		if target.Output == stdoutTarget && (mode != modeGen || report) {
+			return fail(usageErrorf("Only gen without -report can write to stdout"))
+		}
+	}
+	if err := config.discoverTypes(); err != nil {
+		return fail(err)
+	}
+	if config.Static {
+		return convertStatic(config, mode, report)
+	}
+	return runHelper(config, mode, report)
+}
 
-	t := template.Must(template.New("").Parse(TEMPLATE))
+// stringList is a flag which can be given several times.
+type stringList []string
 
-	filename, err := ioutil.TempDir(os.TempDir(), "")
-	handleErr(err)
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
 
-	filename = fmt.Sprintf("%s/typescriptify_%d.go", filename, time.Now().Nanosecond())
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
 
-	f, err := os.Create(filename)
-	handleErr(err)
-	defer f.Close()
+// splitQualified splits a type argument like billing.Invoice or
+// github.com/you/project/billing.Invoice into qualifier and type name.
+func splitQualified(arg string) (string, string) {
+	dot := strings.LastIndex(arg, ".")
+	if dot < 0 || strings.Contains(arg[dot:], "/") {
+		return "", arg
+	}
+	return arg[:dot], arg[dot+1:]
+}
 
-	structsArr := make([]string, 0)
-	for _, str := range structs {
-		str = strings.TrimSpace(str)
-		if len(str) > 0 {
-			structsArr = append(structsArr, pckg+"."+str)
+// qualifiedPackage returns the index of the package a qualifier refers to,
+// either by its path or by the last element of its path. If the qualifier is
+// a path not given with -package, -1 is returned.
+func qualifiedPackage(packages []Package, qualifier string) (int, error) {
+	found := -1
+	for n, pkg := range packages {
+		if path.Clean(pkg.Path) == path.Clean(qualifier) {
+			return n, nil
+		}
+		if path.Base(pkg.Path) == qualifier {
+			if found >= 0 {
+				return 0, fmt.Errorf("%s is ambiguous, qualify the type with the package path", qualifier)
+			}
+			found = n
 		}
 	}
-
-	params := Params{Structs: structsArr, ModelsPackage: packagePath, TargetFile: target, UseInterface: useInterface}
-	err = t.Execute(f, params)
-	handleErr(err)
-
-	cmd := exec.Command("go", "run", filename)
-	fmt.Println(strings.Join(cmd.Args, " "))
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		fmt.Println(string(output))
-		handleErr(err)
+	if found < 0 && !strings.Contains(qualifier, "/") {
+		return 0, fmt.Errorf("No package %s, add it with -package", qualifier)
 	}
-	fmt.Println(string(output))
+	return found, nil
 }
 
-func GetGolangFileStructs(filename string) ([]string, error) {
-	fset := token.NewFileSet() // positions are relative to fset
-
-	f, err := parser.ParseFile(fset, filename, nil, 0)
+// filesPackage returns the index of the package declared in the directory of
+// a file, directory or glob argument.
+func filesPackage(packages []Package, arg, tags string) (int, error) {
+	dir := arg
+	if info, err := os.Stat(arg); err != nil || !info.IsDir() {
+		dir = filepath.Dir(arg)
+	}
+	dir, err := filepath.Abs(dir)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 
-	v := &AVisitor{}
-	ast.Walk(v, f)
-
-	return v.structs, nil
+	for n, pkg := range packages {
+		pkgDir, err := packageDir(pkg.Path, tags)
+		if err != nil {
+			return 0, err
+		}
+		if pkgDir == dir {
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf("%s isn't in any of the packages", arg)
 }
 
-type AVisitor struct {
-	structNameCandidate string
-	structs             []string
-}
+// convertStatic converts the models with the go/types based engine, without
+// generating and running a helper program.
+func convertStatic(config *Config, mode string, report bool) int {
+	code := exitOK
+	reports := make([]typescriptify.Report, 0)
+	for _, target := range config.Targets {
+		converter := target.converter()
+		converter.BuildTags = config.buildTags()
 
-func (v *AVisitor) Visit(node ast.Node) ast.Visitor {
-	if node != nil {
-		switch t := node.(type) {
-		case *ast.Ident:
-			firstChar := string(t.Name[0])
-			if strings.ToUpper(firstChar) == firstChar {
-				v.structNameCandidate = t.Name
-			}
-		case *ast.StructType:
-			if len(v.structNameCandidate) > 0 {
-				v.structs = append(v.structs, v.structNameCandidate)
-				v.structNameCandidate = ""
+		err := addSources(converter, target)
+		var result typescriptify.Report
+		if err != nil {
+			// Failed before converting
+			result = typescriptify.Report{Output: target.Output, Entities: []typescriptify.ReportEntity{}, Warnings: []string{}, Error: err.Error()}
+		} else if mode == modeGen && target.Output == stdoutTarget {
+			err = converter.ConvertTo(os.Stdout)
+			result = converter.Report()
+		} else if mode == modeGen {
+			err = converter.ConvertToFile(target.Output)
+			result = converter.Report()
+		} else {
+			var diff string
+			diff, err = converter.Verify(target.Output)
+			result = converter.Report()
+			if len(diff) > 0 {
+				if !report {
+					fmt.Print(diff)
+				}
+				if mode == modeCheck {
+					fmt.Fprintln(os.Stderr, target.Output, "is out of date")
+					if code == exitOK {
+						code = exitOutdated
+					}
+				}
 			}
-		default:
-			v.structNameCandidate = ""
 		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, target.Output+":", err.Error())
+			code = exitFailed
+		}
+		for _, warning := range result.Warnings {
+			fmt.Fprintln(os.Stderr, target.Output+": warning:", warning)
+		}
+		reports = append(reports, result)
 	}
-	return v
+
+	if report {
+		bytes, err := json.MarshalIndent(reports, "", "  ")
+		if err != nil {
+			return fail(err)
+		}
+		fmt.Println(string(bytes))
+	}
+	return code
 }
 
-func handleErr(err error) {
-	if err != nil {
-		log.Fatal(err.Error())
+func addSources(converter *typescriptify.TypeScriptify, target Target) error {
+	for _, pkg := range target.Packages {
+		for _, name := range pkg.Types {
+			if err := converter.AddSourceType(pkg.Path, name, pkg.Options[name].library()); err != nil {
+				return err
+			}
+		}
 	}
+	return nil
 }
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	watchInterval = 500 * time.Millisecond
+	// Changes are collected until the sources have been quiet for this long,
+	// so saving several files (or a formatter rewriting them) regenerates once.
+	watchDebounce = 300 * time.Millisecond
+)
+
+// watch generates all targets and then polls the source directories of their
+// packages and the packages they import, regenerating only the targets
+// affected by a change. Their types are discovered again and their directories
+// listed again first, so added types and newly imported packages are picked
+// up. Errors are reported and watching continues, it only returns when
+// interrupted.
+func watch(config *Config) int {
+	targets, dirs, err := discoverTargets(config, config.Targets)
+	if err != nil {
+		return fail(err)
+	}
+
+	// Taken before generating, so changes made meanwhile aren't missed
+	stamps := make(map[string]string)
+	watchDirs(stamps, dirs)
+
+	generate(config, targets)
+
+	fmt.Println("Watching for changes...")
+	changed := make(map[string]bool)
+	var lastChange time.Time
+	for {
+		time.Sleep(watchInterval)
+
+		for dir, stamp := range stamps {
+			if current := dirStamp(dir); current != stamp {
+				stamps[dir] = current
+				changed[dir] = true
+				lastChange = time.Now()
+			}
+		}
+
+		if len(changed) == 0 || time.Since(lastChange) < watchDebounce {
+			continue
+		}
+
+		affected := make([]Target, 0)
+		for _, n := range affectedTargets(dirs, changed) {
+			discovered, discoveredDirs, err := discoverTargets(config, config.Targets[n:n+1])
+			if err != nil {
+				fail(err)
+				continue
+			}
+			targets[n], dirs[n] = discovered[0], discoveredDirs[0]
+			affected = append(affected, targets[n])
+		}
+		changed = make(map[string]bool)
+		watchDirs(stamps, dirs)
+
+		fmt.Printf("%s changes detected, regenerating %d target(s)\n", time.Now().Format("15:04:05"), len(affected))
+		generate(config, affected)
+	}
+}
+
+// discoverTargets returns the given targets of the config with the types of
+// their packages discovered, and their source directories. The config is left
+// unchanged, so the types can be discovered again.
+func discoverTargets(config *Config, targets []Target) ([]Target, [][]string, error) {
+	discovered := *config
+	discovered.Targets = make([]Target, 0, len(targets))
+	for _, target := range targets {
+		packages := make([]Package, 0, len(target.Packages))
+		for _, pkg := range target.Packages {
+			pkg.Types = append([]string(nil), pkg.Types...)
+			options := make(map[string]TypeOptions, len(pkg.Options))
+			for name, o := range pkg.Options {
+				options[name] = o
+			}
+			pkg.Options = options
+			packages = append(packages, pkg)
+		}
+		target.Packages = packages
+		discovered.Targets = append(discovered.Targets, target)
+	}
+
+	if err := discovered.discoverTypes(); err != nil {
+		return nil, nil, err
+	}
+	dirs, err := targetDirs(&discovered)
+	if err != nil {
+		return nil, nil, err
+	}
+	return discovered.Targets, dirs, nil
+}
+
+// watchDirs makes stamps cover exactly the directories of the targets, keeping
+// the stamps of those already watched.
+func watchDirs(stamps map[string]string, dirs [][]string) {
+	watched := make(map[string]bool)
+	for _, targetDirs := range dirs {
+		for _, dir := range targetDirs {
+			watched[dir] = true
+			if _, ok := stamps[dir]; !ok {
+				stamps[dir] = dirStamp(dir)
+			}
+		}
+	}
+	for dir := range stamps {
+		if !watched[dir] {
+			delete(stamps, dir)
+		}
+	}
+}
+
+// generate converts the given targets of the config, errors are reported by
+// the engines.
+func generate(config *Config, targets []Target) int {
+	subset := *config
+	subset.Targets = targets
+	if subset.Static {
+		return convertStatic(&subset, modeGen, false)
+	}
+	return runHelper(&subset, modeGen, false)
+}
+
+// targetDirs returns the source directories of every target: those of its
+// packages and of the packages they import, which may declare the types of
+// fields.
+func targetDirs(config *Config) ([][]string, error) {
+	result := make([][]string, 0, len(config.Targets))
+	for _, target := range config.Targets {
+		dirs := make([]string, 0, len(target.Packages))
+		seen := make(map[string]bool)
+		for _, pkg := range target.Packages {
+			deps, err := packageDeps(pkg.Path, config.Tags)
+			if err != nil {
+				return nil, err
+			}
+			for _, dir := range deps {
+				if !seen[dir] {
+					seen[dir] = true
+					dirs = append(dirs, dir)
+				}
+			}
+		}
+		result = append(result, dirs)
+	}
+	return result, nil
+}
+
+// affectedTargets returns the indexes of the targets with a changed source
+// directory, dirs are those of targetDirs.
+func affectedTargets(dirs [][]string, changed map[string]bool) []int {
+	affected := make([]int, 0)
+	for n, targetDirs := range dirs {
+		for _, dir := range targetDirs {
+			if changed[dir] {
+				affected = append(affected, n)
+				break
+			}
+		}
+	}
+	return affected
+}
+
+// dirStamp summarizes the Go files of a directory, it changes when a file is
+// added, removed or modified.
+func dirStamp(dir string) string {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err.Error()
+	}
+
+	var stamp strings.Builder
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".go" {
+			continue
+		}
+		fmt.Fprintf(&stamp, "%s|%d|%d\n", f.Name(), f.Size(), f.ModTime().UnixNano())
+	}
+	return stamp.String()
+}
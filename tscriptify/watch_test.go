@@ -0,0 +1,162 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDirStamp(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tscriptify")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	stamp := dirStamp(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("models"), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+	if dirStamp(dir) != stamp {
+		t.Error("stamp changed by a file which isn't Go source")
+	}
+
+	fileName := filepath.Join(dir, "models.go")
+	if err := ioutil.WriteFile(fileName, []byte("package models\n"), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+	if dirStamp(dir) == stamp {
+		t.Error("stamp not changed by an added file")
+	}
+
+	stamp = dirStamp(dir)
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(fileName, later, later); err != nil {
+		t.Fatal(err.Error())
+	}
+	if dirStamp(dir) == stamp {
+		t.Error("stamp not changed by a modified file")
+	}
+
+	stamp = dirStamp(dir)
+	if err := os.Remove(fileName); err != nil {
+		t.Fatal(err.Error())
+	}
+	if dirStamp(dir) == stamp {
+		t.Error("stamp not changed by a removed file")
+	}
+}
+
+func TestAffectedTargets(t *testing.T) {
+	dirs := [][]string{{"/src/api", "/src/shared"}, {"/src/billing"}, {"/src/admin", "/src/billing"}}
+
+	for _, test := range []struct {
+		changed  []string
+		expected []int
+	}{
+		{[]string{"/src/shared"}, []int{0}},
+		{[]string{"/src/billing"}, []int{1, 2}},
+		{[]string{"/src/api", "/src/admin"}, []int{0, 2}},
+		{[]string{"/src/other"}, []int{}},
+	} {
+		changed := make(map[string]bool)
+		for _, dir := range test.changed {
+			changed[dir] = true
+		}
+		if affected := affectedTargets(dirs, changed); !reflect.DeepEqual(affected, test.expected) {
+			t.Errorf("%v changed: expected %v, got %v", test.changed, test.expected, affected)
+		}
+	}
+}
+
+func TestTargetDirs(t *testing.T) {
+	// The command imports the library, which declares types of its own
+	config := &Config{Targets: []Target{{Output: "models.ts", Packages: []Package{{Path: "."}}}}}
+	dirs, err := targetDirs(config)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	library, err := filepath.Abs(filepath.Join("..", "typescriptify"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	found := false
+	for _, dir := range dirs[0] {
+		found = found || dir == library
+		if filepath.Base(dir) == "fmt" {
+			t.Errorf("standard library directory %s watched", dir)
+		}
+	}
+	if !found {
+		t.Errorf("%s not watched, got %v", library, dirs[0])
+	}
+}
+
+func TestDiscoverTargetsWhileWatching(t *testing.T) {
+	// A package of this module, so it can be listed and import another one
+	dir, err := ioutil.TempDir(".", "watched")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	shared := filepath.Join(dir, "shared")
+	if err := os.Mkdir(shared, 0755); err != nil {
+		t.Fatal(err.Error())
+	}
+	writeSource := func(fileName, source string) {
+		if err := ioutil.WriteFile(fileName, []byte(source), 0644); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	writeSource(filepath.Join(dir, "user.go"), "package models\n\ntype User struct {\n\tName string\n}\n")
+	writeSource(filepath.Join(shared, "money.go"), "package shared\n\ntype Money struct {\n\tCents int\n}\n")
+
+	pkgPath := "./" + filepath.Base(dir)
+	config := &Config{Targets: []Target{{Output: "models.ts", Packages: []Package{{Path: pkgPath}}}}}
+	sharedDir, err := filepath.Abs(shared)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	discover := func() ([]string, []string) {
+		targets, dirs, err := discoverTargets(config, config.Targets)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		return targets[0].Packages[0].Types, dirs[0]
+	}
+
+	types, dirs := discover()
+	if !reflect.DeepEqual(types, []string{"User"}) {
+		t.Errorf("expected [User], got %v", types)
+	}
+	for _, d := range dirs {
+		if d == sharedDir {
+			t.Errorf("%s watched before being imported", d)
+		}
+	}
+
+	// A type using a newly imported package is added while watching
+	importPath, err := resolveImportPath("./"+filepath.Join(filepath.Base(dir), "shared"), "")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	writeSource(filepath.Join(dir, "order.go"), "package models\n\nimport \""+importPath+"\"\n\ntype Order struct {\n\tTotal shared.Money\n}\n")
+
+	types, dirs = discover()
+	if !reflect.DeepEqual(types, []string{"Order", "User"}) {
+		t.Errorf("expected [Order User], got %v", types)
+	}
+	found := false
+	for _, d := range dirs {
+		found = found || d == sharedDir
+	}
+	if !found {
+		t.Errorf("%s not watched, got %v", sharedDir, dirs)
+	}
+	if len(config.Targets[0].Packages[0].Types) > 0 {
+		t.Errorf("config changed by discovery: %v", config.Targets[0].Packages[0].Types)
+	}
+}
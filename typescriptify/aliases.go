@@ -0,0 +1,137 @@
+package typescriptify
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// isAlias reports whether a type is declared as TypeScript type alias, with
+// DeclareAliases named slices, maps and basic types are.
+func (t *TypeScriptify) isAlias(typeOf goType) bool {
+	if !t.DeclareAliases || len(typeOf.Name()) == 0 || len(typeOf.PkgPath()) == 0 || isEnumType(typeOf) {
+		return false
+	}
+	switch typeOf.Kind() {
+	case reflect.Slice, reflect.Map:
+		return true
+	case reflect.Interface:
+		return false
+	}
+	_, basic := t.types[typeOf.Kind()]
+	return basic
+}
+
+// convertAlias declares a type alias, after the declarations of the types it
+// references.
+func (t *TypeScriptify) convertAlias(typeOf goType, entityName string, customCode map[string]string) (string, error) {
+	var chunks string
+	var body string
+	var err error
+	switch typeOf.Kind() {
+	case reflect.Slice:
+		body, chunks, err = t.typeExpr(typeOf.Elem(), customCode)
+		if strings.ContainsAny(body, " |&") {
+			body = "(" + body + ")"
+		}
+		body += "[]"
+	case reflect.Map:
+		body, chunks, err = t.mapExpr(typeOf, customCode)
+	default:
+		body = t.types[typeOf.Kind()]
+		if t.BrandAliases {
+			body = fmt.Sprintf("%s & { readonly __brand: \"%s\" }", body, entityName)
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %s", typeOf.Name(), err.Error())
+	}
+
+	result := fmt.Sprintf("type %s = %s;", entityName, body)
+	if t.DoExportClass {
+		result = "export " + result
+	}
+	return chunks + result, nil
+}
+
+// typeExpr returns the TypeScript type of the elements and values of type
+// aliases, and the declarations of the types it references.
+func (t *TypeScriptify) typeExpr(typeOf goType, customCode map[string]string) (string, string, error) {
+	if typeOf.Kind() == reflect.Ptr {
+		typeOf = typeOf.Elem()
+	}
+	if isDate, nullable := t.dateOf(typeOf); isDate {
+		return t.dateTypeName(nullable), "", nil
+	}
+	mapping, mapped, err := t.mapping(typeOf)
+	if err != nil || mapped {
+		return mapping.tsType(), "", err
+	}
+
+	if t.isAlias(typeOf) || isEnumType(typeOf) || typeOf.Kind() == reflect.Struct {
+		chunk, err := t.convertType(typeOf, customCode)
+		if err != nil {
+			return "", "", err
+		}
+		if len(chunk) > 0 {
+			chunk += "\n"
+		}
+		return t.entityName(typeOf), chunk, nil
+	}
+
+	switch typeOf.Kind() {
+	case reflect.Slice:
+		elem, chunks, err := t.typeExpr(typeOf.Elem(), customCode)
+		if strings.ContainsAny(elem, " |&") {
+			elem = "(" + elem + ")"
+		}
+		return elem + "[]", chunks, err
+	case reflect.Map:
+		expr, chunks, err := t.mapExpr(typeOf, customCode)
+		return expr, chunks, err
+	}
+	if tsType, found := t.types[typeOf.Kind()]; found {
+		return tsType, "", nil
+	}
+	return "", "", fmt.Errorf("Cannot find type for %s", typeOf.String())
+}
+
+func (t *TypeScriptify) mapExpr(typeOf goType, customCode map[string]string) (string, string, error) {
+	keyType, err := t.mapKeyType(typeOf.Key())
+	if err != nil {
+		return "", "", err
+	}
+	var chunks string
+	if isEnumKey(typeOf.Key()) {
+		chunks, err = t.convertType(typeOf.Key(), customCode)
+		if err != nil {
+			return "", "", err
+		}
+		if len(chunks) > 0 {
+			chunks += "\n"
+		}
+	}
+	valueType, valueChunks, err := t.typeExpr(typeOf.Elem(), customCode)
+	return mapType(typeOf.Key(), keyType, valueType), chunks + valueChunks, err
+}
+
+// convertsValues reports whether classes convert the values of a type, or the
+// elements and values of an alias. Nested arrays and maps aren't converted.
+func (t *TypeScriptify) convertsValues(typeOf goType) bool {
+	if typeOf.Kind() == reflect.Ptr {
+		typeOf = typeOf.Elem()
+	}
+	if isDate, _ := t.dateOf(typeOf); isDate {
+		return len(t.DateType.Parse) > 0 || len(t.DateType.Format) > 0
+	}
+	if mapping, mapped, err := t.mapping(typeOf); err == nil && mapped {
+		return mapping.base64
+	}
+	switch typeOf.Kind() {
+	case reflect.Struct:
+		return t.hasCreateFrom(typeOf)
+	case reflect.Slice, reflect.Map:
+		return t.convertsValues(typeOf.Elem())
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package typescriptify
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const backupTimeFormat = "2006-01-02T15_04_05.000"
+
+// Backup is a saved copy of a previously generated file.
+type Backup struct {
+	Path string
+	Time time.Time
+}
+
+func (t TypeScriptify) backupDir(fileName string) string {
+	if len(t.BackupDir) > 0 {
+		return t.BackupDir
+	}
+	return filepath.Dir(fileName)
+}
+
+// backup saves the current content of fileName unless it is missing or equal
+// to newContent, and then applies the retention policy.
+func (t TypeScriptify) backup(fileName string, newContent []byte) error {
+	current, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		// No need to backup, just return:
+		return nil
+	}
+
+	if bytes.Equal(current, newContent) {
+		return nil
+	}
+
+	dir := t.backupDir(fileName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	var backupName string
+	for stamp := time.Now(); ; stamp = stamp.Add(time.Millisecond) {
+		backupName = filepath.Join(dir, fmt.Sprintf("%s-%s.%s", filepath.Base(fileName), stamp.Format(backupTimeFormat), t.BackupExtension))
+		if _, err := os.Stat(backupName); os.IsNotExist(err) {
+			break
+		}
+	}
+
+	err = ioutil.WriteFile(backupName, current, 0644)
+	if err != nil {
+		return err
+	}
+
+	return t.pruneBackups(fileName)
+}
+
+func (t TypeScriptify) pruneBackups(fileName string) error {
+	if t.BackupKeep <= 0 && t.BackupMaxAge <= 0 {
+		return nil
+	}
+
+	backups, err := t.Backups(fileName)
+	if err != nil {
+		return err
+	}
+
+	for n, b := range backups {
+		tooMany := t.BackupKeep > 0 && n >= t.BackupKeep
+		tooOld := t.BackupMaxAge > 0 && time.Since(b.Time) > t.BackupMaxAge
+		if tooMany || tooOld {
+			if err := os.Remove(b.Path); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Backups lists the backups of fileName, newest first.
+func (t TypeScriptify) Backups(fileName string) ([]Backup, error) {
+	if len(t.BackupExtension) == 0 {
+		return nil, nil
+	}
+
+	files, err := ioutil.ReadDir(t.backupDir(fileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	prefix := filepath.Base(fileName) + "-"
+	suffix := "." + t.BackupExtension
+
+	backups := make([]Backup, 0)
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix)
+		// Fractional seconds are accepted even though the layout has none,
+		// this also matches backups saved with older timestamp formats.
+		backupTime, err := time.ParseInLocation("2006-01-02T15_04_05", stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{Path: filepath.Join(t.backupDir(fileName), name), Time: backupTime})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Time.After(backups[j].Time)
+	})
+
+	return backups, nil
+}
+
+// RestoreBackup replaces fileName with the content of backup. The current
+// content is backed up first, so a restore can be undone.
+func (t TypeScriptify) RestoreBackup(fileName string, backup Backup) error {
+	unlock, err := lockFile(fileName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	content, err := ioutil.ReadFile(backup.Path)
+	if err != nil {
+		return err
+	}
+
+	if len(t.BackupExtension) > 0 {
+		err := t.backup(fileName, content)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writeFileAtomic(fileName, content)
+}
@@ -0,0 +1,65 @@
+package typescriptify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupRetentionAndRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "typescriptify")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	fileName := filepath.Join(dir, "models.ts")
+	backupDir := filepath.Join(dir, "backups")
+
+	converter := New()
+	converter.BackupDir = backupDir
+	converter.BackupKeep = 2
+
+	for _, content := range []string{"one", "two", "three", "three"} {
+		if err := ioutil.WriteFile(fileName, []byte(content), 0644); err != nil {
+			t.Fatal(err.Error())
+		}
+		converter.Add(Address{})
+		if err := converter.ConvertToFile(fileName); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	backups, err := converter.Backups(fileName)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 backups, got %d", len(backups))
+	}
+
+	// Unchanged output must not produce another backup
+	if err := converter.ConvertToFile(fileName); err != nil {
+		t.Fatal(err.Error())
+	}
+	if again, _ := converter.Backups(fileName); len(again) != 2 {
+		t.Fatalf("expected 2 backups after unchanged conversion, got %d", len(again))
+	}
+
+	if err := converter.RestoreBackup(fileName, backups[0]); err != nil {
+		t.Fatal(err.Error())
+	}
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if string(content) != "three" {
+		t.Errorf("restored content: %q", string(content))
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 2 {
+		t.Errorf("backups written next to the target file")
+	}
+}
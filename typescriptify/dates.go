@@ -0,0 +1,128 @@
+package typescriptify
+
+import (
+	"fmt"
+)
+
+// DateType is the TypeScript representation of dates, which are RFC 3339
+// strings in JSON. Classes convert them with the Parse and Format
+// expressions of the string or date `value` in createFrom and toJSON.
+type DateType struct {
+	Type        string // TypeScript type
+	Declaration string // Declaration of Type, added once to the output
+	Import      string // Import statement of Type, added once to the output
+	Parse       string // Converts the string value in createFrom, e.g. "DateTime.fromISO(value)", none if empty
+	Format      string // Converts the date value in toJSON, e.g. "value.toISO()", none if empty
+}
+
+// Predefined date types, DateObject is the default.
+var (
+	DateString    = DateType{Type: "string"}
+	DateISOString = DateType{Type: "ISODateString", Declaration: `type ISODateString = string & { readonly __brand: "ISODateString" };`}
+	DateObject    = DateType{Type: "Date", Parse: "new Date(value)"}
+)
+
+// dateOf reports whether values of a type are dates, and if they may be null.
+// Mappings with Date set are dates.
+func (t *TypeScriptify) dateOf(typeOf goType) (isDate, nullable bool) {
+	if mapping, found := t.mappings[typeOf.PkgPath()+"."+typeOf.Name()]; found && mapping.Date {
+		return true, mapping.Nullable
+	}
+	return t.isDate(typeOf), false
+}
+
+// dateTypeName returns the TypeScript type of dates, used by the converted
+// types. Its import is added to the output.
+func (t *TypeScriptify) dateTypeName(nullable bool) string {
+	t.usesDate = true
+	if len(t.DateType.Import) > 0 {
+		t.addImports([]string{t.DateType.Import})
+	}
+	if nullable {
+		return t.DateType.Type + " | null"
+	}
+	return t.DateType.Type
+}
+
+// fieldDateType returns the TypeScript type of the dates of a field.
+// Interfaces can't parse values, with a Parse expression they declare the
+// JSON strings.
+func (t *TypeScriptify) fieldDateType(typeOf goType, field goField, nullable, isInterface bool) string {
+	if !isInterface || len(t.DateType.Parse) == 0 {
+		return t.dateTypeName(nullable)
+	}
+	t.warn("%s.%s: dates aren't parsed in interfaces, declared as string", typeOf.Name(), field.Name)
+	if nullable {
+		return "string | null"
+	}
+	return "string"
+}
+
+// dateDeclarations returns the declarations used by date fields.
+func (t *TypeScriptify) dateDeclarations() []string {
+	result := make([]string, 0)
+	if !t.usesDate {
+		return result
+	}
+	export := ""
+	if t.DoExportClass {
+		export = "export "
+	}
+	if len(t.DateType.Declaration) > 0 {
+		result = append(result, export+t.DateType.Declaration)
+	}
+	if t.usesDateParse {
+		result = append(result, fmt.Sprintf("%sfunction parseDate(value: string): %s {\n%sreturn %s;\n}", export, t.DateType.Type, t.Indent, t.DateType.Parse))
+	}
+	if t.usesDateFormat {
+		result = append(result, fmt.Sprintf("%sfunction formatDate(value: %s): string {\n%sreturn %s;\n}", export, t.DateType.Type, t.Indent, t.DateType.Format))
+	}
+	return result
+}
+
+// AddDateField adds a date field, converted by the parseDate and formatDate
+// functions if the DateType has Parse and Format expressions.
+func (t *typeScriptClassBuilder) AddDateField(fieldName, fieldType string) {
+	t.addField(fieldName, fieldType)
+	if len(t.dateType.Parse) > 0 {
+		t.usesDateParse = true
+		t.createFromMethodBody += fmt.Sprintf("%s%sresult.%s = source[\"%s\"] != null ? parseDate(source[\"%s\"]) : %s;\n", t.indent, t.indent, fieldName, fieldName, fieldName, t.orElse(fieldName))
+	} else {
+		t.createFromMethodBody += fmt.Sprintf("%s%sresult.%s = source[\"%s\"];\n", t.indent, t.indent, fieldName, fieldName)
+	}
+	if len(t.dateType.Format) > 0 {
+		t.usesDateFormat = true
+		t.toJSONFields += fmt.Sprintf("%s%s%s%s: this.%s != null ? formatDate(this.%s) : null,\n", t.indent, t.indent, t.indent, fieldName, fieldName, fieldName)
+	}
+}
+
+// AddDateArrayField adds an array of dates, see AddDateField. Null elements,
+// of pointers and nullable dates, aren't converted.
+func (t *typeScriptClassBuilder) AddDateArrayField(fieldName, fieldType string) {
+	t.addField(fieldName, fieldType)
+	if len(t.dateType.Parse) > 0 {
+		t.usesDateParse = true
+		t.createFromMethodBody += fmt.Sprintf("%s%sresult.%s = source[\"%s\"] ? source[\"%s\"].map((%s) => element != null ? parseDate(element) : null) : %s;\n", t.indent, t.indent, fieldName, fieldName, fieldName, t.elementParam(), t.orElse(fieldName))
+	} else {
+		t.createFromMethodBody += fmt.Sprintf("%s%sresult.%s = source[\"%s\"];\n", t.indent, t.indent, fieldName, fieldName)
+	}
+	if len(t.dateType.Format) > 0 {
+		t.usesDateFormat = true
+		t.toJSONFields += fmt.Sprintf("%s%s%s%s: this.%s ? this.%s.map((element) => element != null ? formatDate(element) : null) : null,\n", t.indent, t.indent, t.indent, fieldName, fieldName, fieldName)
+	}
+}
+
+// AddDateMapField adds a map of dates, see AddDateArrayField.
+func (t *typeScriptClassBuilder) AddDateMapField(fieldName, fieldType string) {
+	t.addField(fieldName, fieldType)
+	if len(t.dateType.Parse) > 0 {
+		t.usesDateParse = true
+		t.createFromMethodBody += fmt.Sprintf("%s%sresult.%s = source[\"%s\"] ? Object.fromEntries(Object.entries(source[\"%s\"]).map(([key, value]) => [key, value != null ? parseDate(value) : null])) : %s;\n", t.indent, t.indent, fieldName, fieldName, fieldName, t.orElse(fieldName))
+	} else {
+		t.createFromMethodBody += fmt.Sprintf("%s%sresult.%s = source[\"%s\"];\n", t.indent, t.indent, fieldName, fieldName)
+	}
+	if len(t.dateType.Format) > 0 {
+		t.usesDateFormat = true
+		t.toJSONFields += fmt.Sprintf("%s%s%s%s: this.%s ? Object.fromEntries(Object.entries(this.%s).map(([key, value]) => [key, value != null ? formatDate(value) : null])) : null,\n", t.indent, t.indent, t.indent, fieldName, fieldName, fieldName)
+	}
+}
@@ -0,0 +1,220 @@
+package typescriptify
+
+import (
+	"fmt"
+	"strings"
+)
+
+const diffContext = 3
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// unifiedDiff returns the differences between from and to in unified format,
+// or an empty string when they are equal.
+func unifiedDiff(fromName, toName, from, to string) string {
+	ops := diffLines(splitLines(from), splitLines(to))
+
+	changed := false
+	for _, op := range ops {
+		if op.kind != ' ' {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return ""
+	}
+
+	var result strings.Builder
+	fmt.Fprintf(&result, "--- %s\n+++ %s\n", fromName, toName)
+
+	// Line numbers (0 based) in from and to at the start of each op
+	fromLines := make([]int, len(ops)+1)
+	toLines := make([]int, len(ops)+1)
+	for i, op := range ops {
+		fromLines[i+1], toLines[i+1] = fromLines[i], toLines[i]
+		if op.kind != '+' {
+			fromLines[i+1]++
+		}
+		if op.kind != '-' {
+			toLines[i+1]++
+		}
+	}
+
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		// Extend the hunk while changes are closer than two contexts apart
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for j := i; j < len(ops) && j-end <= 2*diffContext; j++ {
+			if ops[j].kind != ' ' {
+				end = j
+			}
+		}
+		end += diffContext + 1
+		if end > len(ops) {
+			end = len(ops)
+		}
+
+		fmt.Fprintf(&result, "@@ -%s +%s @@\n",
+			hunkRange(fromLines[start], fromLines[end]-fromLines[start]),
+			hunkRange(toLines[start], toLines[end]-toLines[start]))
+		for _, op := range ops[start:end] {
+			result.WriteByte(op.kind)
+			result.WriteString(op.line)
+			result.WriteByte('\n')
+		}
+
+		i = end
+	}
+
+	return result.String()
+}
+
+func hunkRange(start, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if length == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
+
+// noNewline marks a last line without newline, it differs from the same line
+// with newline and is printed after it like by diff.
+const noNewline = "\n\\ No newline at end of file"
+
+func splitLines(s string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	if !strings.HasSuffix(s, "\n") {
+		lines[len(lines)-1] += noNewline
+	}
+	return lines
+}
+
+// maxDiffEdits caps the number of edits diffLines searches, its memory grows
+// with their square. Lines differing more are replaced as a whole.
+const maxDiffEdits = 1000
+
+// diffLines computes the edit script between a and b. The lines between the
+// common prefix and suffix are compared with the Myers algorithm.
+func diffLines(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	ops = append(ops, myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+// myersDiff computes the shortest edit script between a and b, or replaces a
+// with b if it has more than maxDiffEdits edits.
+func myersDiff(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	// Diagonals -d..d of v before step d, those used by the step
+	trace := make([][]int, 0)
+
+search:
+	for d := 0; d <= max; d++ {
+		if d > maxDiffEdits {
+			return replaceLines(a, b)
+		}
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, max)
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[d+k-1] < v[d+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[d+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			ops = append(ops, diffOp{' ', a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			ops = append(ops, diffOp{'+', b[y-1]})
+		} else {
+			ops = append(ops, diffOp{'-', a[x-1]})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		ops = append(ops, diffOp{' ', a[x-1]})
+		x--
+		y--
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+
+	return ops
+}
+
+// replaceLines returns the edit script removing all of a and adding all of b.
+func replaceLines(a, b []string) []diffOp {
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, line := range a {
+		ops = append(ops, diffOp{'-', line})
+	}
+	for _, line := range b {
+		ops = append(ops, diffOp{'+', line})
+	}
+	return ops
+}
@@ -0,0 +1,56 @@
+package typescriptify
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	from := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\n"
+	to := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n"
+
+	desiredResult := `--- old
++++ new
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -10,3 +10,4 @@
+ j
+ k
+ l
++m
+`
+	if diff := unifiedDiff("old", "new", from, to); diff != desiredResult {
+		t.Errorf("Expected:\n%s\nGot:\n%s", desiredResult, diff)
+	}
+
+	if diff := unifiedDiff("old", "new", from, from); diff != "" {
+		t.Errorf("Expected no diff, got:\n%s", diff)
+	}
+
+	diff := unifiedDiff("old", "new", "", "a\n")
+	if !strings.Contains(diff, "@@ -0,0 +1 @@\n+a\n") {
+		t.Errorf("Unexpected diff for new file:\n%s", diff)
+	}
+
+	diff = unifiedDiff("old", "new", "x\ny\n", "x\ny")
+	if !strings.Contains(diff, "@@ -1,2 +1,2 @@\n x\n-y\n+y\n\\ No newline at end of file\n") {
+		t.Errorf("Unexpected diff for missing newline:\n%s", diff)
+	}
+
+	// Reindented, more edits than searched
+	var reindented, original strings.Builder
+	for i := 0; i < 4000; i++ {
+		fmt.Fprintf(&original, "    line %d\n", i)
+		fmt.Fprintf(&reindented, "\tline %d\n", i)
+	}
+	diff = unifiedDiff("old", "new", original.String(), reindented.String())
+	if !strings.HasPrefix(diff, "--- old\n+++ new\n@@ -1,4000 +1,4000 @@\n-    line 0\n") || strings.Count(diff, "\n+\t") != 4000 {
+		t.Errorf("Unexpected diff for reindented lines:\n%.200s", diff)
+	}
+}
@@ -0,0 +1,114 @@
+package typescriptify
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	lockRetryInterval = 50 * time.Millisecond
+	lockTimeout       = 30 * time.Second
+	// A lock file older than this is considered left over by a crashed process.
+	lockStaleAge = 2 * time.Minute
+)
+
+// lockFile acquires an advisory lock for fileName by exclusively creating
+// fileName.lock. Every generator process uses the same lock file, so writes to
+// the same target are serialized. The returned function releases the lock.
+func lockFile(fileName string) (func(), error) {
+	lockName := fileName + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(lockName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			ours, err := f.Stat()
+			f.Close()
+			if err != nil {
+				os.Remove(lockName)
+				return nil, err
+			}
+			return func() { removeOwnLock(lockName, ours) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		if info, err := os.Stat(lockName); err == nil && time.Since(info.ModTime()) > lockStaleAge {
+			breakStaleLock(lockName, info)
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("Timeout waiting for lock %s", lockName)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// breakStaleLock removes the stale lock file checked by the caller. It is
+// renamed first, so only one waiting process takes it over, and a fresh lock
+// another process created in the meantime is put back. Inodes are reused,
+// the modification time tells them apart.
+func breakStaleLock(lockName string, stale os.FileInfo) {
+	staleName := fmt.Sprintf("%s.%d.stale", lockName, os.Getpid())
+	if err := os.Rename(lockName, staleName); err != nil {
+		return
+	}
+	if info, err := os.Stat(staleName); err == nil && !(os.SameFile(info, stale) && info.ModTime().Equal(stale.ModTime())) {
+		os.Link(staleName, lockName)
+	}
+	os.Remove(staleName)
+}
+
+// removeOwnLock releases a lock unless it was taken over as stale by another
+// process meanwhile.
+func removeOwnLock(lockName string, ours os.FileInfo) {
+	if info, err := os.Stat(lockName); err == nil && os.SameFile(info, ours) && info.ModTime().Equal(ours.ModTime()) {
+		os.Remove(lockName)
+	}
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory and
+// renames it over fileName. Readers see either the old or the new content,
+// never a partially written file.
+func writeFileAtomic(fileName string, data []byte) error {
+	dir, base := filepath.Split(fileName)
+	if len(dir) == 0 {
+		dir = "."
+	}
+
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(fileName); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tmp, err := ioutil.TempFile(dir, "."+base+".tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpName, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmpName, fileName)
+	}
+	if err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	return nil
+}
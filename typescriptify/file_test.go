@@ -0,0 +1,85 @@
+package typescriptify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStaleLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "typescriptify")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "models.ts")
+	lockName := fileName + ".lock"
+
+	if err := ioutil.WriteFile(lockName, []byte("1\n"), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+	old := time.Now().Add(-2 * lockStaleAge)
+	if err := os.Chtimes(lockName, old, old); err != nil {
+		t.Fatal(err.Error())
+	}
+	stale, err := os.Stat(lockName)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// Another waiter already replaced the stale lock with its own
+	if err := os.Remove(lockName); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := ioutil.WriteFile(lockName, []byte("2\n"), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+	breakStaleLock(lockName, stale)
+	if content, err := ioutil.ReadFile(lockName); err != nil || string(content) != "2\n" {
+		t.Fatalf("fresh lock removed: %v", err)
+	}
+
+	if err := os.Chtimes(lockName, old, old); err != nil {
+		t.Fatal(err.Error())
+	}
+	unlock, err := lockFile(fileName)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	unlock()
+	if files, _ := ioutil.ReadDir(dir); len(files) > 0 {
+		t.Errorf("files left over: %s", files[0].Name())
+	}
+}
+
+func TestUnlockTakenOver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "typescriptify")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "models.ts")
+	lockName := fileName + ".lock"
+
+	unlock, err := lockFile(fileName)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	// Another process took the lock over as stale
+	if err := os.Remove(lockName); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := ioutil.WriteFile(lockName, []byte("2\n"), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(lockName, later, later); err != nil {
+		t.Fatal(err.Error())
+	}
+	unlock()
+	if content, err := ioutil.ReadFile(lockName); err != nil || string(content) != "2\n" {
+		t.Errorf("lock of the other process removed: %v", err)
+	}
+}
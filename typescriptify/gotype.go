@@ -0,0 +1,133 @@
+package typescriptify
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// goType is the view of a Go type used by the converter. It is implemented
+// with reflection (reflectType) and statically with go/types (staticType), so
+// both engines share the same conversion code and produce the same output.
+//
+// Implementations must be comparable, equal values describe the same type.
+type goType interface {
+	Name() string
+	// String returns the type as reflect.Type.String() does, e.g. "time.Time".
+	String() string
+	PkgPath() string
+	Kind() reflect.Kind
+	Elem() goType
+	Key() goType
+	// PtrTo returns the pointer type with this element type.
+	PtrTo() goType
+	NumField() int
+	Field(i int) goField
+	// Implements reports whether the method set of the type contains the
+	// methods of the interface type u.
+	Implements(u reflect.Type) bool
+	// EnumValues returns the String() values of an int enum.
+	EnumValues() ([]string, error)
+	// EnumText reports whether the encoding.TextMarshaler of an int enum
+	// encodes its values as their String().
+	EnumText() bool
+	// TypeScriptType returns the results of the methods of TypeScriptTyper
+	// and TypeScriptImporter, for types implementing TypeScriptTyper.
+	TypeScriptType() (string, []string, error)
+}
+
+type goField struct {
+	Name      string
+	Type      goType
+	Tag       reflect.StructTag
+	Anonymous bool
+	Exported  bool
+}
+
+// maxEnumValue is the highest value tried when collecting enum values.
+const maxEnumValue = 10000
+
+type reflectType struct {
+	reflect.Type
+}
+
+func (r reflectType) Elem() goType {
+	return reflectType{r.Type.Elem()}
+}
+
+func (r reflectType) PtrTo() goType {
+	return reflectType{reflect.PtrTo(r.Type)}
+}
+
+func (r reflectType) Key() goType {
+	return reflectType{r.Type.Key()}
+}
+
+func (r reflectType) Field(i int) goField {
+	f := r.Type.Field(i)
+	return goField{
+		Name:      f.Name,
+		Type:      reflectType{f.Type},
+		Tag:       f.Tag,
+		Anonymous: f.Anonymous,
+		Exported:  f.IsExported(),
+	}
+}
+
+func (r reflectType) EnumValues() ([]string, error) {
+	values := make([]string, 0)
+
+	val := reflect.New(r.Type).Elem()
+	for i := 0; i < maxEnumValue; i++ {
+		val.SetInt(int64(i))
+		arr := val.MethodByName("String").Call(nil)
+		if len(arr) == 1 {
+			enumVal := fmt.Sprintf("%+v", arr[0])
+
+			if strings.Contains(enumVal, fmt.Sprintf("(%d)", i)) {
+				continue
+			}
+			values = append(values, enumVal)
+		}
+	}
+
+	return values, nil
+}
+
+func (r reflectType) EnumText() bool {
+	// MarshalText may have a pointer receiver
+	ptr := reflect.New(r.Type)
+	marshaler, ok := ptr.Interface().(encoding.TextMarshaler)
+	stringer, isStringer := ptr.Elem().Interface().(fmt.Stringer)
+	if !ok || !isStringer {
+		return false
+	}
+	for i := 0; i < maxEnumValue; i++ {
+		ptr.Elem().SetInt(int64(i))
+		stringer = ptr.Elem().Interface().(fmt.Stringer)
+		str := stringer.String()
+		if strings.Contains(str, fmt.Sprintf("(%d)", i)) {
+			continue
+		}
+		text, err := marshaler.MarshalText()
+		if err != nil || string(text) != str {
+			return false
+		}
+	}
+	return true
+}
+
+func (r reflectType) TypeScriptType() (string, []string, error) {
+	// The methods may have pointer receivers
+	value := reflect.New(r.Type).Interface()
+	typer, ok := value.(TypeScriptTyper)
+	if !ok {
+		return "", nil, fmt.Errorf("%s doesn't implement TypeScriptTyper", r.String())
+	}
+	var imports []string
+	if importer, ok := value.(TypeScriptImporter); ok {
+		imports = importer.TypeScriptImports()
+	}
+	return typer.TypeScriptType(), imports, nil
+}
@@ -0,0 +1,266 @@
+package typescriptify
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Mapping gives the TypeScript type of a Go type which isn't converted into a
+// declaration of its own, usually because of its JSON encoding. Values of
+// mapped types are assigned as they are in createFrom.
+type Mapping struct {
+	Type     string // TypeScript type
+	Nullable bool   // The JSON value may be null
+	Date     bool   // The type is a date, Type is ignored for the DateType
+
+	base64 bool // Decoded by classes, set by bytesMapping
+}
+
+func (m Mapping) tsType() string {
+	if m.Nullable {
+		return m.Type + " | null"
+	}
+	return m.Type
+}
+
+// arrayType returns the type of an array of the mapped type.
+func (m Mapping) arrayType() string {
+	if m.Nullable {
+		return "(" + m.tsType() + ")[]"
+	}
+	return m.Type + "[]"
+}
+
+// AddMapping maps a Go type given by its import path and name, like
+// "github.com/google/uuid.UUID", to a TypeScript type.
+func (t *TypeScriptify) AddMapping(goType string, mapping Mapping) {
+	if t.mappings == nil {
+		t.mappings = make(map[string]Mapping)
+	}
+	t.mappings[goType] = mapping
+}
+
+// UseMappings adds the mappings of the given packs, see MappingPacks.
+func (t *TypeScriptify) UseMappings(packs ...string) error {
+	for _, name := range packs {
+		pack, found := mappingPacks[name]
+		if !found {
+			return fmt.Errorf("Unknown mapping pack %s", name)
+		}
+		for goType, mapping := range pack {
+			t.AddMapping(goType, mapping)
+		}
+	}
+	return nil
+}
+
+// TypeScriptTyper is implemented by Go types declaring their own TypeScript
+// type, it is used verbatim wherever they are referenced. With AddSource the
+// method isn't run, it must return a constant.
+type TypeScriptTyper interface {
+	TypeScriptType() string
+}
+
+// TypeScriptImporter is implemented by TypeScriptTyper types referencing
+// declarations of other modules, like `import { Money } from "./money";`.
+// The import statements are added once to the top of the output.
+type TypeScriptImporter interface {
+	TypeScriptImports() []string
+}
+
+var (
+	typeScriptTyper = reflect.TypeOf((*TypeScriptTyper)(nil)).Elem()
+	textMarshaler   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonMarshaler   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// mapping returns the mapping of a type, if any. Types implementing
+// TypeScriptTyper declare their own, those implementing
+// encoding.TextMarshaler are strings. The JSON of a json.Marshaler can't be
+// known, it needs an added mapping. Dates and enums keep their conversion.
+func (t *TypeScriptify) mapping(typeOf goType) (Mapping, bool, error) {
+	if len(typeOf.Name()) == 0 || typeOf.Kind() == reflect.Interface {
+		return t.bytesMapping(typeOf)
+	}
+	name := typeOf.PkgPath() + "." + typeOf.Name()
+	if mapping, found := t.mappings[name]; found {
+		return mapping, true, nil
+	}
+	if name == "encoding/json.RawMessage" || name == "encoding/json/jsontext.Value" {
+		if t.RawMessageType == "unknown" {
+			return Mapping{Type: t.unknownType()}, true, nil
+		}
+		return Mapping{Type: t.RawMessageType}, true, nil
+	}
+	if marshals(typeOf, typeScriptTyper) {
+		tsType, imports, err := typeOf.TypeScriptType()
+		if err != nil {
+			return Mapping{}, false, err
+		}
+		t.addImports(imports)
+		return Mapping{Type: tsType}, true, nil
+	}
+	if t.isDate(typeOf) || isEnumType(typeOf) {
+		return Mapping{}, false, nil
+	}
+	if marshals(typeOf, jsonMarshaler) {
+		return Mapping{}, false, fmt.Errorf("%s implements json.Marshaler, add a mapping for %s.%s", typeOf.String(), typeOf.PkgPath(), typeOf.Name())
+	}
+	if marshals(typeOf, textMarshaler) {
+		return Mapping{Type: "string"}, true, nil
+	}
+	return t.bytesMapping(typeOf)
+}
+
+// bytesMapping maps byte slices, encoding/json encodes them as base64 strings.
+func (t *TypeScriptify) bytesMapping(typeOf goType) (Mapping, bool, error) {
+	if typeOf.Kind() != reflect.Slice || typeOf.Elem().Kind() != reflect.Uint8 {
+		return Mapping{}, false, nil
+	}
+	if !t.Base64Type {
+		return Mapping{Type: "string"}, true, nil
+	}
+	t.usesBase64 = true
+	return Mapping{Type: "Base64", base64: true}, true, nil
+}
+
+// base64Declaration declares the Base64 type and the functions converting it
+// used with Base64Type.
+func (t *TypeScriptify) base64Declaration() string {
+	export := ""
+	if t.DoExportClass {
+		export = "export "
+	}
+	return export + "type Base64 = string & { readonly __brand: \"Base64\" };\n\n" +
+		export + "function decodeBase64(value: Base64): Uint8Array {\n" +
+		t.Indent + "return Uint8Array.from(atob(value), (c) => c.charCodeAt(0));\n" +
+		"}\n\n" +
+		export + "function encodeBase64(value: Uint8Array): Base64 {\n" +
+		t.Indent + "return btoa(Array.from(value, (b) => String.fromCharCode(b)).join(\"\")) as Base64;\n" +
+		"}"
+}
+
+// AddBase64Field adds a Uint8Array field decoded from base64 by createFrom and
+// encoded back by toJSON.
+func (t *typeScriptClassBuilder) AddBase64Field(fieldName string) {
+	t.addField(fieldName, "Uint8Array")
+	t.createFromMethodBody += fmt.Sprintf("%s%sresult.%s = source[\"%s\"] != null ? decodeBase64(source[\"%s\"]) : %s;\n", t.indent, t.indent, fieldName, fieldName, fieldName, t.orElse(fieldName))
+	t.toJSONFields += fmt.Sprintf("%s%s%s%s: this.%s != null ? encodeBase64(this.%s) : null,\n", t.indent, t.indent, t.indent, fieldName, fieldName, fieldName)
+}
+
+// AddBase64ArrayField adds an array of Uint8Array, see AddBase64Field.
+func (t *typeScriptClassBuilder) AddBase64ArrayField(fieldName string) {
+	t.addField(fieldName, "Uint8Array[]")
+	t.createFromMethodBody += fmt.Sprintf("%s%sresult.%s = source[\"%s\"] ? source[\"%s\"].map((%s) => element != null ? decodeBase64(element) : null) : %s;\n", t.indent, t.indent, fieldName, fieldName, fieldName, t.elementParam(), t.orElse(fieldName))
+	t.toJSONFields += fmt.Sprintf("%s%s%s%s: this.%s ? this.%s.map((element) => element != null ? encodeBase64(element) : null) : null,\n", t.indent, t.indent, t.indent, fieldName, fieldName, fieldName)
+}
+
+// AddBase64MapField adds a map of Uint8Array, see AddBase64Field.
+func (t *typeScriptClassBuilder) AddBase64MapField(fieldName, fieldType string) {
+	t.addField(fieldName, fieldType)
+	t.createFromMethodBody += fmt.Sprintf("%s%sresult.%s = source[\"%s\"] ? Object.fromEntries(Object.entries(source[\"%s\"]).map(([key, value]) => [key, value != null ? decodeBase64(value) : null])) : %s;\n", t.indent, t.indent, fieldName, fieldName, fieldName, t.orElse(fieldName))
+	t.toJSONFields += fmt.Sprintf("%s%s%s%s: this.%s ? Object.fromEntries(Object.entries(this.%s).map(([key, value]) => [key, value != null ? encodeBase64(value) : null])) : null,\n", t.indent, t.indent, t.indent, fieldName, fieldName, fieldName)
+}
+
+// marshals reports whether encoding/json uses the methods of u for values of
+// a type. Methods with pointer receivers are used for addressable values,
+// e.g. the fields of a marshaled pointer.
+func marshals(typeOf goType, u reflect.Type) bool {
+	return typeOf.Implements(u) || typeOf.PtrTo().Implements(u)
+}
+
+func (t *TypeScriptify) addImports(imports []string) {
+	for _, imp := range imports {
+		if !t.imported[imp] {
+			t.imported[imp] = true
+			t.imports = append(t.imports, imp)
+		}
+	}
+}
+
+// MappingPacks returns the names of the mapping packs usable with UseMappings.
+func MappingPacks() []string {
+	names := make([]string, 0, len(mappingPacks))
+	for name := range mappingPacks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// gureguNullTypes are the types of github.com/guregu/null, all of them
+// marshal to null when invalid.
+var gureguNullTypes = map[string]Mapping{
+	"String": {Type: "string", Nullable: true},
+	"Int":    {Type: "number", Nullable: true},
+	"Float":  {Type: "number", Nullable: true},
+	"Bool":   {Type: "boolean", Nullable: true},
+	"Time":   {Date: true, Nullable: true},
+}
+
+func packageTypes(importPaths []string, types map[string]Mapping) map[string]Mapping {
+	result := make(map[string]Mapping)
+	for _, importPath := range importPaths {
+		for name, mapping := range types {
+			result[importPath+"."+name] = mapping
+		}
+	}
+	return result
+}
+
+// mappingPacks hold the mappings of common types by the JSON they marshal to.
+var mappingPacks = map[string]map[string]Mapping{
+	// The sql.Null types have no JSON methods, they marshal as structs
+	"database/sql": {
+		"database/sql.NullString":  {Type: "{ String: string; Valid: boolean }"},
+		"database/sql.NullInt64":   {Type: "{ Int64: number; Valid: boolean }"},
+		"database/sql.NullInt32":   {Type: "{ Int32: number; Valid: boolean }"},
+		"database/sql.NullInt16":   {Type: "{ Int16: number; Valid: boolean }"},
+		"database/sql.NullByte":    {Type: "{ Byte: number; Valid: boolean }"},
+		"database/sql.NullFloat64": {Type: "{ Float64: number; Valid: boolean }"},
+		"database/sql.NullBool":    {Type: "{ Bool: boolean; Valid: boolean }"},
+		"database/sql.NullTime":    {Type: "{ Time: string; Valid: boolean }"},
+	},
+	"encoding/json": {
+		"encoding/json.Number": {Type: "number"},
+	},
+	"time": {
+		"time.Duration": {Type: "number"}, // nanoseconds
+		"time.Month":    {Type: "number"},
+		"time.Weekday":  {Type: "number"},
+	},
+	"net": {
+		"net.IP":             {Type: "string"},
+		"net/netip.Addr":     {Type: "string"},
+		"net/netip.AddrPort": {Type: "string"},
+		"net/netip.Prefix":   {Type: "string"},
+	},
+	"math/big": {
+		"math/big.Int":   {Type: "number"},
+		"math/big.Float": {Type: "string"},
+		"math/big.Rat":   {Type: "string"},
+	},
+	"guregu/null": packageTypes([]string{
+		"github.com/guregu/null",
+		"gopkg.in/guregu/null.v3",
+		"gopkg.in/guregu/null.v4",
+		"github.com/guregu/null/v5",
+	}, gureguNullTypes),
+	// UUIDs are [16]byte arrays marshaled as text
+	"uuid": {
+		"github.com/google/uuid.UUID":        {Type: "string"},
+		"github.com/gofrs/uuid.UUID":         {Type: "string"},
+		"github.com/satori/go.uuid.UUID":     {Type: "string"},
+		"github.com/google/uuid.NullUUID":    {Type: "string", Nullable: true},
+		"github.com/gofrs/uuid.NullUUID":     {Type: "string", Nullable: true},
+		"github.com/satori/go.uuid.NullUUID": {Type: "string", Nullable: true},
+	},
+	// Decimals marshal as strings unless decimal.MarshalJSONWithoutQuotes is set
+	"shopspring/decimal": {
+		"github.com/shopspring/decimal.Decimal":     {Type: "string"},
+		"github.com/shopspring/decimal.NullDecimal": {Type: "string", Nullable: true},
+	},
+}
@@ -0,0 +1,93 @@
+package typescriptify
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Values of Int64Type, the TypeScript type of int64 and uint64.
+const (
+	Int64Number = "number" // Precision is lost above 2^53
+	Int64String = "string" // Fields with the ",string" option
+	Int64BigInt = "bigint" // Fields with the ",string" option, converted by createFrom and toJSON of classes
+)
+
+// fieldTag is the struct tag with options of a single field, e.g.
+// `tscriptify:"int64=string"`.
+const fieldTag = "tscriptify"
+
+// fieldOption returns the value of an option in the tscriptify tag of a field.
+func fieldOption(field goField, name string) string {
+	for _, option := range strings.Split(field.Tag.Get(fieldTag), ",") {
+		parts := strings.SplitN(option, "=", 2)
+		if len(parts) == 2 && strings.TrimSpace(parts[0]) == name {
+			return strings.TrimSpace(parts[1])
+		}
+	}
+	return ""
+}
+
+// int64Type returns the Int64Type of a field, it can be overridden with the
+// tag `tscriptify:"int64=bigint"`.
+func (t *TypeScriptify) int64Type(field goField) (string, error) {
+	int64Type := t.Int64Type
+	if option := fieldOption(field, "int64"); len(option) > 0 {
+		int64Type = option
+	}
+	switch int64Type {
+	case "":
+		return Int64Number, nil
+	case Int64Number, Int64String:
+		return int64Type, nil
+	case Int64BigInt:
+		if !t.supports(3, 2) {
+			return "", fmt.Errorf("bigint needs TypeScript 3.2, the version is %s", t.TypeScriptVersion)
+		}
+		return int64Type, nil
+	}
+	return "", fmt.Errorf("Unknown int64 type %s, use number, string or bigint", int64Type)
+}
+
+func isInt64(kind reflect.Kind) bool {
+	return kind == reflect.Int64 || kind == reflect.Uint64
+}
+
+// isQuoted reports whether the value of a field is encoded as JSON string by
+// the ",string" option.
+func isQuoted(jsonTag string, kind reflect.Kind) bool {
+	hasOption := false
+	for _, option := range strings.Split(jsonTag, ",")[1:] {
+		hasOption = hasOption || option == "string"
+	}
+	if !hasOption {
+		return false
+	}
+	switch kind {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// AddBigIntField adds a bigint field converted from the JSON string of the
+// ",string" option by createFrom, and back by toJSON.
+func (t *typeScriptClassBuilder) AddBigIntField(fieldName string) {
+	t.addField(fieldName, "bigint")
+	t.createFromMethodBody += fmt.Sprintf("%s%sresult.%s = source[\"%s\"] != null ? BigInt(source[\"%s\"]) : %s;\n", t.indent, t.indent, fieldName, fieldName, fieldName, t.orElse(fieldName))
+	t.toJSONFields += fmt.Sprintf("%s%s%s%s: this.%s != null ? String(this.%s) : null,\n", t.indent, t.indent, t.indent, fieldName, fieldName, fieldName)
+}
+
+// toJSONMethod returns the toJSON method of a class with bigint fields.
+func (t *typeScriptClassBuilder) toJSONMethod() string {
+	result := fmt.Sprintf("%stoJSON() {\n", t.indent)
+	result += fmt.Sprintf("%s%sreturn {\n", t.indent, t.indent)
+	result += fmt.Sprintf("%s%s%s...this,\n", t.indent, t.indent, t.indent)
+	result += t.toJSONFields
+	result += fmt.Sprintf("%s%s};\n", t.indent, t.indent)
+	result += fmt.Sprintf("%s}\n\n", t.indent)
+	return result
+}
@@ -0,0 +1,64 @@
+package typescriptify
+
+import (
+	"bytes"
+	"sort"
+	"time"
+)
+
+// Output stores generated files somewhere else than the local filesystem,
+// several converters can write their files into the same Output.
+type Output interface {
+	// Existing returns the current content of a file, nil if there is none
+	Existing(fileName string) ([]byte, error)
+	Write(fileName string, content []byte) error
+}
+
+// ConvertToOutput converts all added types and writes them as fileName to
+// output, keeping the custom code blocks of the existing content. Unchanged
+// content isn't written. Unlike ConvertToFile there is no locking and no
+// backup.
+func (t *TypeScriptify) ConvertToOutput(output Output, fileName string) (err error) {
+	t.report = newReport(fileName)
+	defer func(start time.Time) {
+		t.report.finish(start, err)
+	}(time.Now())
+
+	existing, err := output.Existing(fileName)
+	if err != nil {
+		return err
+	}
+
+	content, err := t.render(existing)
+	if err != nil {
+		return err
+	}
+	t.report.Changed = !bytes.Equal(existing, content)
+	if !t.report.Changed {
+		return nil
+	}
+
+	return output.Write(fileName, content)
+}
+
+// MemoryOutput keeps files in memory, e.g. for tests.
+type MemoryOutput map[string][]byte
+
+func (m MemoryOutput) Existing(fileName string) ([]byte, error) {
+	return m[fileName], nil
+}
+
+func (m MemoryOutput) Write(fileName string, content []byte) error {
+	m[fileName] = content
+	return nil
+}
+
+// Files returns the names of the files, sorted.
+func (m MemoryOutput) Files() []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
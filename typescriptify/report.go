@@ -0,0 +1,51 @@
+package typescriptify
+
+import (
+	"fmt"
+	"time"
+)
+
+// Report describes the last conversion, see TypeScriptify.Report.
+type Report struct {
+	Output      string         `json:"output,omitempty"` // Target file, empty for Convert
+	Entities    []ReportEntity `json:"entities"`
+	Warnings    []string       `json:"warnings"`
+	Changed     bool           `json:"changed"`        // The target file was, or by Verify would be, changed
+	Diff        string         `json:"diff,omitempty"` // Set by Verify
+	Error       string         `json:"error,omitempty"`
+	Fingerprint string         `json:"fingerprint,omitempty"` // See the header of generated files
+	Duration    time.Duration  `json:"duration_ns"`
+}
+
+// ReportEntity is a declared TypeScript class, interface or enum.
+type ReportEntity struct {
+	Name    string `json:"name"`
+	Kind    string `json:"kind"`
+	GoType  string `json:"go_type"`
+	Package string `json:"package"`
+}
+
+func newReport(output string) Report {
+	return Report{
+		Output:   output,
+		Entities: make([]ReportEntity, 0),
+		Warnings: make([]string, 0),
+	}
+}
+
+func (r *Report) finish(start time.Time, err error) {
+	r.Duration = time.Since(start)
+	if err != nil {
+		r.Error = err.Error()
+	}
+}
+
+// Report returns the report of the last Convert, ConvertToFile or Verify.
+func (t *TypeScriptify) Report() Report {
+	return t.report
+}
+
+// warn records a problem which didn't stop the conversion.
+func (t *TypeScriptify) warn(format string, args ...interface{}) {
+	t.report.Warnings = append(t.report.Warnings, fmt.Sprintf(format, args...))
+}
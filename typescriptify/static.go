@@ -0,0 +1,642 @@
+package typescriptify
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/constant"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"os/exec"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// AddSource adds the named types declared in the package with the given
+// import path. Unlike Add, the package is loaded from source with go/parser
+// and go/types, so user code is neither compiled nor executed. Relative paths
+// like "./models" are resolved against the current directory.
+func (t *TypeScriptify) AddSource(pkgPath string, names ...string) error {
+	for _, name := range names {
+		if err := t.AddSourceType(pkgPath, name, TypeOptions{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddSourceType is like AddSource for a single type, with options.
+func (t *TypeScriptify) AddSourceType(pkgPath, name string, options TypeOptions) error {
+	if t.loader == nil {
+		t.loader = newSourceLoader("", t.BuildTags)
+	}
+
+	pkg, err := t.loader.load(pkgPath)
+	if err != nil {
+		return err
+	}
+
+	obj, ok := pkg.types.Scope().Lookup(name).(*types.TypeName)
+	if !ok {
+		return fmt.Errorf("Type %s not found in package %s", name, pkgPath)
+	}
+	t.addType(t.loader.goType(obj.Type()), options)
+
+	return nil
+}
+
+type sourcePackage struct {
+	types *types.Package
+	files []*ast.File
+	err   error
+}
+
+// sourceLoader parses and type checks packages, including their dependencies,
+// from source. It implements types.ImporterFrom, so every package is loaded
+// only once and types from different packages can be compared.
+type sourceLoader struct {
+	dir      string
+	fset     *token.FileSet
+	ctx      build.Context
+	packages map[string]*sourcePackage
+}
+
+func newSourceLoader(dir string, buildTags []string) *sourceLoader {
+	if len(dir) == 0 {
+		dir, _ = os.Getwd()
+	}
+
+	ctx := build.Default
+	// Cgo files can't be type checked without running cgo, the pure Go
+	// variants of the standard library are used instead.
+	ctx.CgoEnabled = false
+	ctx.BuildTags = buildTags
+
+	return &sourceLoader{
+		dir:      dir,
+		fset:     token.NewFileSet(),
+		ctx:      ctx,
+		packages: make(map[string]*sourcePackage),
+	}
+}
+
+func (l *sourceLoader) goType(typ types.Type) goType {
+	return staticType{typ: types.Unalias(typ), loader: l}
+}
+
+func (l *sourceLoader) load(pkgPath string) (*sourcePackage, error) {
+	if build.IsLocalImport(pkgPath) {
+		canonical, err := canonicalImportPath(pkgPath, l.dir)
+		if err != nil {
+			return nil, err
+		}
+		pkgPath = canonical
+	}
+
+	if _, err := l.ImportFrom(pkgPath, l.dir, 0); err != nil {
+		return nil, err
+	}
+
+	pkg := l.packages[pkgPath]
+	if pkg == nil {
+		return nil, fmt.Errorf("Package %s not loaded", pkgPath)
+	}
+	if pkg.err != nil {
+		return nil, pkg.err
+	}
+	return pkg, nil
+}
+
+// canonicalImportPath asks the go command for the import path of a package
+// given by a relative directory.
+func canonicalImportPath(pkgPath, dir string) (string, error) {
+	cmd := exec.Command("go", "list", "-f", "{{.ImportPath}}", pkgPath)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("Cannot resolve package %s: %s", pkgPath, err.Error())
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func (l *sourceLoader) Import(path string) (*types.Package, error) {
+	return l.ImportFrom(path, l.dir, 0)
+}
+
+func (l *sourceLoader) ImportFrom(path, dir string, mode types.ImportMode) (*types.Package, error) {
+	if path == "unsafe" {
+		return types.Unsafe, nil
+	}
+
+	bp, err := l.ctx.Import(path, dir, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if pkg, found := l.packages[bp.ImportPath]; found {
+		if pkg.types == nil {
+			return nil, fmt.Errorf("Import cycle through %s", bp.ImportPath)
+		}
+		return pkg.types, nil
+	}
+	pkg := &sourcePackage{}
+	l.packages[bp.ImportPath] = pkg
+
+	for _, name := range bp.GoFiles {
+		f, err := parser.ParseFile(l.fset, bp.Dir+string(os.PathSeparator)+name, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		pkg.files = append(pkg.files, f)
+	}
+
+	conf := types.Config{
+		Importer:         l,
+		IgnoreFuncBodies: true,
+		FakeImportC:      true,
+		Error: func(err error) {
+			if pkg.err == nil {
+				pkg.err = err
+			}
+		},
+	}
+	pkg.types, _ = conf.Check(bp.ImportPath, l.fset, pkg.files, nil)
+
+	return pkg.types, nil
+}
+
+// staticType implements goType for types loaded by a sourceLoader.
+type staticType struct {
+	typ    types.Type
+	loader *sourceLoader
+}
+
+var basicKinds = map[types.BasicKind]reflect.Kind{
+	types.Bool:          reflect.Bool,
+	types.Int:           reflect.Int,
+	types.Int8:          reflect.Int8,
+	types.Int16:         reflect.Int16,
+	types.Int32:         reflect.Int32,
+	types.Int64:         reflect.Int64,
+	types.Uint:          reflect.Uint,
+	types.Uint8:         reflect.Uint8,
+	types.Uint16:        reflect.Uint16,
+	types.Uint32:        reflect.Uint32,
+	types.Uint64:        reflect.Uint64,
+	types.Uintptr:       reflect.Uintptr,
+	types.Float32:       reflect.Float32,
+	types.Float64:       reflect.Float64,
+	types.Complex64:     reflect.Complex64,
+	types.Complex128:    reflect.Complex128,
+	types.String:        reflect.String,
+	types.UnsafePointer: reflect.UnsafePointer,
+}
+
+func (s staticType) Name() string {
+	switch t := s.typ.(type) {
+	case *types.Named:
+		return t.Obj().Name()
+	case *types.Basic:
+		return basicKinds[t.Kind()].String()
+	}
+	return ""
+}
+
+func (s staticType) String() string {
+	if b, ok := s.typ.(*types.Basic); ok {
+		return basicKinds[b.Kind()].String()
+	}
+	return types.TypeString(s.typ, func(p *types.Package) string {
+		return p.Name()
+	})
+}
+
+func (s staticType) PkgPath() string {
+	if named, ok := s.typ.(*types.Named); ok && named.Obj().Pkg() != nil {
+		return named.Obj().Pkg().Path()
+	}
+	return ""
+}
+
+func (s staticType) Kind() reflect.Kind {
+	switch t := s.typ.Underlying().(type) {
+	case *types.Basic:
+		return basicKinds[t.Kind()]
+	case *types.Pointer:
+		return reflect.Ptr
+	case *types.Slice:
+		return reflect.Slice
+	case *types.Array:
+		return reflect.Array
+	case *types.Map:
+		return reflect.Map
+	case *types.Chan:
+		return reflect.Chan
+	case *types.Signature:
+		return reflect.Func
+	case *types.Struct:
+		return reflect.Struct
+	case *types.Interface:
+		return reflect.Interface
+	}
+	return reflect.Invalid
+}
+
+func (s staticType) Elem() goType {
+	switch t := s.typ.Underlying().(type) {
+	case *types.Pointer:
+		return s.loader.goType(t.Elem())
+	case *types.Slice:
+		return s.loader.goType(t.Elem())
+	case *types.Array:
+		return s.loader.goType(t.Elem())
+	case *types.Map:
+		return s.loader.goType(t.Elem())
+	case *types.Chan:
+		return s.loader.goType(t.Elem())
+	}
+	panic("typescriptify: Elem of invalid type " + s.String())
+}
+
+func (s staticType) PtrTo() goType {
+	return s.loader.goType(types.NewPointer(s.typ))
+}
+
+func (s staticType) Key() goType {
+	if m, ok := s.typ.Underlying().(*types.Map); ok {
+		return s.loader.goType(m.Key())
+	}
+	panic("typescriptify: Key of non-map type " + s.String())
+}
+
+func (s staticType) NumField() int {
+	if st, ok := s.typ.Underlying().(*types.Struct); ok {
+		return st.NumFields()
+	}
+	panic("typescriptify: NumField of non-struct type " + s.String())
+}
+
+func (s staticType) Field(i int) goField {
+	st := s.typ.Underlying().(*types.Struct)
+	v := st.Field(i)
+	return goField{
+		Name:      v.Name(),
+		Type:      s.loader.goType(v.Type()),
+		Tag:       reflect.StructTag(st.Tag(i)),
+		Anonymous: v.Embedded(),
+		Exported:  v.Exported(),
+	}
+}
+
+func (s staticType) Implements(u reflect.Type) bool {
+	methods := types.NewMethodSet(s.typ)
+	for i := 0; i < u.NumMethod(); i++ {
+		m := u.Method(i)
+		sel := methods.Lookup(nil, m.Name)
+		if sel == nil {
+			return false
+		}
+		sig, ok := sel.Type().(*types.Signature)
+		if !ok || sig.Params().Len() != m.Type.NumIn() || sig.Results().Len() != m.Type.NumOut() {
+			return false
+		}
+	}
+	return true
+}
+
+// EnumValues finds the constants declared with the enum type and computes
+// their String() values without running the method. String methods made of
+// a switch, a lookup in a map or array literal, or generated by stringer are
+// understood. Otherwise the constant names are used.
+func (s staticType) EnumValues() ([]string, error) {
+	named, ok := s.typ.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return nil, fmt.Errorf("Cannot find values of enum %s", s.String())
+	}
+	pkg := s.loader.packages[named.Obj().Pkg().Path()]
+	if pkg == nil {
+		return nil, fmt.Errorf("Package of enum %s not loaded", s.String())
+	}
+
+	consts := make([]*types.Const, 0)
+	scope := pkg.types.Scope()
+	for _, name := range scope.Names() {
+		if c, ok := scope.Lookup(name).(*types.Const); ok && types.Identical(c.Type(), named) {
+			consts = append(consts, c)
+		}
+	}
+	sort.SliceStable(consts, func(i, j int) bool {
+		return consts[i].Pos() < consts[j].Pos()
+	})
+
+	stringOf := s.stringMethod(pkg, named.Obj().Name())
+
+	names := make(map[int64]string)
+	keys := make([]int64, 0)
+	for _, c := range consts {
+		v, exact := constant.Int64Val(c.Val())
+		if !exact || v < 0 || v >= maxEnumValue {
+			continue
+		}
+		if _, found := names[v]; found {
+			continue
+		}
+		name, ok := stringOf[v]
+		if !ok {
+			name = c.Name()
+		}
+		names[v] = name
+		keys = append(keys, v)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	values := make([]string, 0, len(keys))
+	for _, k := range keys {
+		values = append(values, names[k])
+	}
+	return values, nil
+}
+
+// stringMethod statically evaluates the String() method of typeName into a
+// map from values to their strings.
+func (s staticType) stringMethod(pkg *sourcePackage, typeName string) map[int64]string {
+	result := make(map[int64]string)
+
+	method := findMethod(pkg, typeName, "String")
+	if method == nil {
+		return result
+	}
+
+	var offset int64
+	ast.Inspect(method.Body, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.AssignStmt:
+			// stringer shifts values not starting at zero with "i -= N"
+			if n.Tok == token.SUB_ASSIGN && len(n.Rhs) == 1 {
+				if v, ok := s.evalInt(pkg, n.Rhs[0]); ok {
+					offset = v
+				}
+			}
+		case *ast.CaseClause:
+			if len(n.Body) != 1 {
+				return true
+			}
+			ret, ok := n.Body[0].(*ast.ReturnStmt)
+			if !ok || len(ret.Results) != 1 {
+				return true
+			}
+			str, ok := s.evalString(pkg, ret.Results[0])
+			if !ok {
+				return true
+			}
+			for _, expr := range n.List {
+				if v, ok := s.evalInt(pkg, expr); ok {
+					result[v] = str
+				}
+			}
+		case *ast.ReturnStmt:
+			if len(n.Results) != 1 {
+				return true
+			}
+			switch expr := n.Results[0].(type) {
+			case *ast.IndexExpr:
+				// return names[i]
+				for v, str := range s.stringLiterals(pkg, expr.X) {
+					if _, found := result[v]; !found {
+						result[v] = str
+					}
+				}
+			case *ast.SliceExpr:
+				// return _T_name[_T_index[i]:_T_index[i+1]]
+				name, ok := s.evalString(pkg, expr.X)
+				low, lowOK := expr.Low.(*ast.IndexExpr)
+				if !ok || !lowOK {
+					return true
+				}
+				bounds := s.intLiterals(pkg, low.X)
+				for i := int64(0); i+1 < int64(len(bounds)); i++ {
+					if bounds[i] <= bounds[i+1] && bounds[i+1] <= int64(len(name)) {
+						result[i+offset] = name[bounds[i]:bounds[i+1]]
+					}
+				}
+			}
+		}
+		return true
+	})
+
+	return result
+}
+
+// TypeScriptType evaluates the TypeScriptType and TypeScriptImports methods
+// without running them. Their single return statement must return a constant
+// and a literal of constants.
+func (s staticType) TypeScriptType() (string, []string, error) {
+	named, ok := s.typ.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return "", nil, fmt.Errorf("Cannot find methods of %s", s.String())
+	}
+	pkg := s.loader.packages[named.Obj().Pkg().Path()]
+	if pkg == nil {
+		return "", nil, fmt.Errorf("Package of %s not loaded", s.String())
+	}
+	typeName := named.Obj().Name()
+
+	tsType, ok := s.evalString(pkg, returnedExpr(findMethod(pkg, typeName, "TypeScriptType")))
+	if !ok {
+		return "", nil, fmt.Errorf("Cannot evaluate %s.TypeScriptType() statically, it must return a constant", s.String())
+	}
+
+	importer := reflect.TypeOf((*TypeScriptImporter)(nil)).Elem()
+	if !s.Implements(importer) && !s.PtrTo().Implements(importer) {
+		return tsType, nil, nil
+	}
+	var imports []string
+	switch expr := returnedExpr(findMethod(pkg, typeName, "TypeScriptImports")).(type) {
+	case *ast.Ident:
+		ok = expr.Name == "nil"
+	case *ast.CompositeLit:
+		for _, elt := range expr.Elts {
+			var str string
+			if str, ok = s.evalString(pkg, elt); !ok {
+				break
+			}
+			imports = append(imports, str)
+		}
+	default:
+		ok = false
+	}
+	if !ok {
+		return "", nil, fmt.Errorf("Cannot evaluate %s.TypeScriptImports() statically, it must return a literal of constants", s.String())
+	}
+	return tsType, imports, nil
+}
+
+// EnumText recognizes MarshalText methods made of the single statement
+// `return []byte(v.String()), nil`, others can't be evaluated statically.
+func (s staticType) EnumText() bool {
+	named, ok := s.typ.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return false
+	}
+	pkg := s.loader.packages[named.Obj().Pkg().Path()]
+	if pkg == nil {
+		return false
+	}
+	fn := findMethod(pkg, named.Obj().Name(), "MarshalText")
+	if fn == nil || len(fn.Body.List) != 1 || len(fn.Recv.List[0].Names) != 1 {
+		return false
+	}
+	ret, ok := fn.Body.List[0].(*ast.ReturnStmt)
+	if !ok || len(ret.Results) != 2 || types.ExprString(ret.Results[1]) != "nil" {
+		return false
+	}
+	conversion, ok := ret.Results[0].(*ast.CallExpr)
+	if !ok || len(conversion.Args) != 1 || types.ExprString(conversion.Fun) != "[]byte" {
+		return false
+	}
+	return types.ExprString(conversion.Args[0]) == fn.Recv.List[0].Names[0].Name+".String()"
+}
+
+// findMethod returns the declaration of a method of typeName, nil if there is
+// none in the package.
+func findMethod(pkg *sourcePackage, typeName, name string) *ast.FuncDecl {
+	for _, f := range pkg.files {
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if ok && fn.Name.Name == name && fn.Body != nil && receiverName(fn) == typeName {
+				return fn
+			}
+		}
+	}
+	return nil
+}
+
+// returnedExpr returns the result of a function made of a single return
+// statement.
+func returnedExpr(fn *ast.FuncDecl) ast.Expr {
+	if fn == nil || len(fn.Body.List) != 1 {
+		return nil
+	}
+	ret, ok := fn.Body.List[0].(*ast.ReturnStmt)
+	if !ok || len(ret.Results) != 1 {
+		return nil
+	}
+	return ret.Results[0]
+}
+
+func receiverName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) != 1 {
+		return ""
+	}
+	typ := fn.Recv.List[0].Type
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	if ident, ok := typ.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+func (s staticType) eval(pkg *sourcePackage, expr ast.Expr) (constant.Value, bool) {
+	if expr == nil {
+		return nil, false
+	}
+	tv, err := types.Eval(s.loader.fset, pkg.types, token.NoPos, types.ExprString(expr))
+	if err != nil || tv.Value == nil {
+		return nil, false
+	}
+	return tv.Value, true
+}
+
+func (s staticType) evalInt(pkg *sourcePackage, expr ast.Expr) (int64, bool) {
+	v, ok := s.eval(pkg, expr)
+	if !ok || v.Kind() != constant.Int {
+		return 0, false
+	}
+	return constant.Int64Val(v)
+}
+
+func (s staticType) evalString(pkg *sourcePackage, expr ast.Expr) (string, bool) {
+	v, ok := s.eval(pkg, expr)
+	if !ok || v.Kind() != constant.String {
+		return "", false
+	}
+	return constant.StringVal(v), true
+}
+
+// packageVar returns the composite literal a package level variable is
+// initialized with.
+func (s staticType) packageVar(pkg *sourcePackage, expr ast.Expr) *ast.CompositeLit {
+	ident, ok := expr.(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	for _, f := range pkg.files {
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.VAR {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				vs := spec.(*ast.ValueSpec)
+				for i, name := range vs.Names {
+					if name.Name == ident.Name && i < len(vs.Values) {
+						lit, _ := vs.Values[i].(*ast.CompositeLit)
+						return lit
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// stringLiterals evaluates a map or array literal of strings, indexed by
+// integer keys.
+func (s staticType) stringLiterals(pkg *sourcePackage, expr ast.Expr) map[int64]string {
+	result := make(map[int64]string)
+	lit := s.packageVar(pkg, expr)
+	if lit == nil {
+		return result
+	}
+
+	var index int64
+	for _, elt := range lit.Elts {
+		value := elt
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			key, ok := s.evalInt(pkg, kv.Key)
+			if !ok {
+				continue
+			}
+			index = key
+			value = kv.Value
+		}
+		if str, ok := s.evalString(pkg, value); ok {
+			result[index] = str
+		}
+		index++
+	}
+	return result
+}
+
+// intLiterals evaluates an array literal of integers.
+func (s staticType) intLiterals(pkg *sourcePackage, expr ast.Expr) []int64 {
+	lit := s.packageVar(pkg, expr)
+	if lit == nil {
+		return nil
+	}
+
+	result := make([]int64, 0, len(lit.Elts))
+	for _, elt := range lit.Elts {
+		v, ok := s.evalInt(pkg, elt)
+		if !ok {
+			return nil
+		}
+		result = append(result, v)
+	}
+	return result
+}
@@ -0,0 +1,38 @@
+package typescriptify
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/amanbolat/go-tscriptify/typescriptify/testdata/models"
+)
+
+func TestStaticMatchesReflection(t *testing.T) {
+	for _, useInterface := range []bool{false, true} {
+		reflection := New()
+		reflection.UseInterface = useInterface
+		reflection.Add(models.Item{})
+		reflection.Add(models.Status(0))
+
+		static := New()
+		static.UseInterface = useInterface
+		err := static.AddSource("github.com/amanbolat/go-tscriptify/typescriptify/testdata/models", "Item", "Status")
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		desiredResult, err := reflection.Convert(nil)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		testConverter(t, static, strings.Trim(desiredResult, " \t\n\r"))
+	}
+}
+
+func TestStaticTypeNotFound(t *testing.T) {
+	converter := New()
+	err := converter.AddSource("github.com/amanbolat/go-tscriptify/typescriptify/testdata/models", "Missing")
+	if err == nil {
+		t.Fatal("expected an error for a missing type")
+	}
+}
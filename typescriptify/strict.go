@@ -0,0 +1,74 @@
+package typescriptify
+
+import (
+	"fmt"
+)
+
+// checkVersion validates TypeScriptVersion, which is "major.minor".
+func (t *TypeScriptify) checkVersion() error {
+	if len(t.TypeScriptVersion) == 0 {
+		return nil
+	}
+	var major, minor int
+	if _, err := fmt.Sscanf(t.TypeScriptVersion, "%d.%d", &major, &minor); err != nil {
+		return fmt.Errorf("Invalid TypeScript version %s, expected e.g. 4.9", t.TypeScriptVersion)
+	}
+	return nil
+}
+
+// supports reports whether the output may use syntax introduced with the
+// given TypeScript version. Without TypeScriptVersion everything is used.
+func (t *TypeScriptify) supports(major, minor int) bool {
+	var targetMajor, targetMinor int
+	if _, err := fmt.Sscanf(t.TypeScriptVersion, "%d.%d", &targetMajor, &targetMinor); err != nil {
+		return true
+	}
+	return targetMajor > major || targetMajor == major && targetMinor >= minor
+}
+
+// unknownType returns unknown, or any before TypeScript 3.0.
+func (t *TypeScriptify) unknownType() string {
+	if t.supports(3, 0) {
+		return "unknown"
+	}
+	return "any"
+}
+
+// interfaceType returns the TypeScript type of interface{}.
+func (t *TypeScriptify) interfaceType() string {
+	if t.Strict {
+		return t.unknownType()
+	}
+	return "any"
+}
+
+// addField declares a field, as alias if the field has one. In strict classes
+// fields are definitely assigned, they are set by createFrom or the code
+// creating the object.
+func (t *typeScriptClassBuilder) addField(fieldName, fieldType string) {
+	if len(t.alias) > 0 {
+		fieldType = t.alias
+	}
+	definite := ""
+	if t.definite {
+		definite = "!"
+	}
+	t.fields += fmt.Sprintf("%s%s%s: %s;\n", t.indent, fieldName, definite, fieldType)
+}
+
+// orElse returns the value createFrom assigns to a field of a missing object
+// or array. In strict mode it is the source value, as null isn't assignable.
+func (t *typeScriptClassBuilder) orElse(fieldName string) string {
+	if t.strict {
+		return fmt.Sprintf("source[\"%s\"]", fieldName)
+	}
+	return "null"
+}
+
+// elementParam returns the parameter of createFrom callbacks.
+func (t *typeScriptClassBuilder) elementParam() string {
+	if t.strict {
+		return "element: any"
+	}
+	return "element"
+}
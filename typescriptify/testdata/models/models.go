@@ -0,0 +1,111 @@
+// Package models is used to test that the reflection and the static
+// conversion produce the same output.
+package models
+
+import (
+	"strconv"
+	"time"
+)
+
+type Status int
+
+const (
+	StatusActive Status = iota
+	StatusDisabled
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusActive:
+		return "active"
+	case StatusDisabled:
+		return "disabled"
+	}
+	return "Status(" + strconv.Itoa(int(s)) + ")"
+}
+
+// Color is a stringer generated enum
+type Color int
+
+const (
+	Red Color = iota + 1
+	Green
+	Blue
+)
+
+const _Color_name = "RedGreenBlue"
+
+var _Color_index = [...]uint8{0, 3, 8, 12}
+
+func (i Color) String() string {
+	i -= 1
+	if i < 0 || i >= Color(len(_Color_index)-1) {
+		return "Color(" + strconv.FormatInt(int64(i+1), 10) + ")"
+	}
+	return _Color_name[_Color_index[i]:_Color_index[i+1]]
+}
+
+func (i Color) MarshalText() ([]byte, error) {
+	return []byte(i.String()), nil
+}
+
+// Version marshals as text, like "1.2"
+type Version struct {
+	Major int
+	Minor int
+}
+
+func (v *Version) MarshalText() ([]byte, error) {
+	return []byte(strconv.Itoa(v.Major) + "." + strconv.Itoa(v.Minor)), nil
+}
+
+// Money declares its own TypeScript type
+type Money struct {
+	Cents    int64
+	Currency string
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(`{"amount":"` + strconv.FormatInt(m.Cents, 10) + `","currency":"` + m.Currency + `"}`), nil
+}
+
+const moneyModule = "./money"
+
+func (m Money) TypeScriptType() string {
+	return "Money"
+}
+
+func (m *Money) TypeScriptImports() []string {
+	return []string{`import { Money } from "` + moneyModule + `";`}
+}
+
+type Base struct {
+	ID      int64     `json:"id"`
+	Created time.Time `json:"created"`
+}
+
+type Tag struct {
+	Name  string `json:"name"`
+	Color Color  `json:"color"`
+}
+
+type Item struct {
+	Base
+	Title    string            `json:"title"`
+	Status   Status            `json:"status"`
+	Tags     []Tag             `json:"tags"`
+	Parent   *Item             `json:"parent"`
+	Labels   map[string]string `json:"labels"`
+	Children map[string]*Tag   `json:"children"`
+	Extra    interface{}       `json:"extra"`
+	Scores   []float64         `json:"scores"`
+	Version  Version           `json:"version"`
+	Versions []Version         `json:"versions"`
+	Price    Money             `json:"price"`
+	Prices   map[string]Money  `json:"prices"`
+	Checksum []byte            `json:"checksum"`
+	ByStatus map[Status]int    `json:"by_status"`
+	ByColor  map[Color]int     `json:"by_color"`
+	Ignored  string            `json:"-"`
+	internal string
+}
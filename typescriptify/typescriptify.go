@@ -1,8 +1,12 @@
 package typescriptify
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"reflect"
@@ -12,21 +16,53 @@ import (
 	"github.com/guregu/null"
 )
 
+// Version of the generator, it is part of the fingerprint of generated files.
+const Version = "0.2.0"
+
 type TypeScriptify struct {
 	Prefix           string
 	Suffix           string
 	Indent           string
 	CreateFromMethod bool
 	DoExportClass    bool
-	BackupExtension  string // If empty no backup
+	BackupExtension  string        // If empty no backup
+	BackupDir        string        // If empty backups are saved next to the target file
+	BackupKeep       int           // Number of backups to keep, 0 keeps all
+	BackupMaxAge     time.Duration // Backups older than this are removed, 0 keeps all
 	UseInterface     bool
-
-	golangTypes []reflect.Type
+	Int64Type        string   // Int64Number (default), Int64String or Int64BigInt, overridden by `tscriptify:"int64=..."`
+	DateType         DateType // DateObject by default
+	BuildTags        []string // Build tags used by AddSource
+	RawMessageType   string   // TypeScript type of json.RawMessage, "unknown" by default
+	Base64Type       bool     // Decode []byte into Uint8Array in classes, interfaces use the branded type Base64
+	DeclareAliases   bool     // Declare named slices, maps and basic types as type aliases
+	BrandAliases     bool     // Brand the aliases of basic types, e.g. string & { readonly __brand: "Email" }
+	SkipUnsupported  bool     // Skip fields encoding/json can't encode with a warning, instead of failing
+
+	// Strict output compiles with the strict TypeScript options: interface{}
+	// is unknown, class fields are definitely assigned and parameters typed.
+	Strict            bool
+	TypeScriptVersion string // Oldest TypeScript version used, e.g. "4.9", the newest if empty
+
+	golangTypes []goType
+	typeOptions map[goType]TypeOptions
 	types       map[reflect.Kind]string
 	dateTypes   []reflect.Type
+	mappings    map[string]Mapping // by import path and name
+
+	// loads packages for AddSource
+	loader *sourceLoader
 
 	// throwaway, used when converting
-	alreadyConverted map[reflect.Type]bool
+	alreadyConverted map[goType]bool
+	declared         map[string]goType
+	imports          []string // of TypeScriptImporter types, in order of use
+	imported         map[string]bool
+	usesBase64       bool
+	usesDate         bool
+	usesDateParse    bool
+	usesDateFormat   bool
+	report           Report
 }
 
 func New() *TypeScriptify {
@@ -48,6 +84,7 @@ func New() *TypeScriptify {
 	types[reflect.Uint16] = "number"
 	types[reflect.Uint32] = "number"
 	types[reflect.Uint64] = "number"
+	types[reflect.Uintptr] = "number"
 	types[reflect.Float32] = "number"
 	types[reflect.Float64] = "number"
 
@@ -61,14 +98,16 @@ func New() *TypeScriptify {
 	}
 
 	result.Indent = "    "
+	result.RawMessageType = "unknown"
+	result.DateType = DateObject
 	result.CreateFromMethod = true
 	result.DoExportClass = true
 
 	return result
 }
 
-func deepFields(typeOf reflect.Type) []reflect.StructField {
-	fields := make([]reflect.StructField, 0)
+func deepFields(typeOf goType) []goField {
+	fields := make([]goField, 0)
 
 	if typeOf.Kind() == reflect.Ptr {
 		typeOf = typeOf.Elem()
@@ -93,16 +132,96 @@ func deepFields(typeOf reflect.Type) []reflect.StructField {
 	return fields
 }
 
+// TypeOptions override the converter settings for one type.
+type TypeOptions struct {
+	Name      string // Used instead of the Go type name, Prefix and Suffix are still added
+	Interface bool   // Declare an interface even if UseInterface is false
+	Class     bool   // Declare a class even if UseInterface is true
+}
+
 func (t *TypeScriptify) Add(obj interface{}) {
 	t.AddType(reflect.TypeOf(obj))
 }
 
 func (t *TypeScriptify) AddType(typeOf reflect.Type) {
+	t.addType(reflectType{typeOf}, TypeOptions{})
+}
+
+func (t *TypeScriptify) AddTypeWithOptions(typeOf reflect.Type, options TypeOptions) {
+	t.addType(reflectType{typeOf}, options)
+}
+
+func (t *TypeScriptify) addType(typeOf goType, options TypeOptions) {
 	t.golangTypes = append(t.golangTypes, typeOf)
+	if options != (TypeOptions{}) {
+		if t.typeOptions == nil {
+			t.typeOptions = make(map[goType]TypeOptions)
+		}
+		t.typeOptions[typeOf] = options
+	}
+}
+
+// entityName returns the TypeScript name of a converted type, used both in
+// its declaration and wherever it is referenced.
+func (t *TypeScriptify) entityName(typeOf goType) string {
+	name := typeOf.Name()
+	if options := t.typeOptions[typeOf]; len(options.Name) > 0 {
+		name = options.Name
+	}
+	return t.Prefix + t.Suffix + name
+}
+
+// isInterface reports whether a struct is declared as TypeScript interface.
+func (t *TypeScriptify) isInterface(typeOf goType) bool {
+	options := t.typeOptions[typeOf]
+	if options.Interface || options.Class {
+		return options.Interface
+	}
+	return t.UseInterface
+}
+
+// isEnumType reports whether a type is declared as TypeScript enum.
+func isEnumType(typeOf goType) bool {
+	stringer := reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	return typeOf.Kind() == reflect.Int && typeOf.Implements(stringer)
+}
+
+func (t *TypeScriptify) isDate(typeOf goType) bool {
+	for _, v := range t.dateTypes {
+		if v.String() == typeOf.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// hasCreateFrom reports whether the TypeScript declaration of a struct has a
+// createFrom method.
+func (t *TypeScriptify) hasCreateFrom(typeOf goType) bool {
+	return t.CreateFromMethod && !t.isDate(typeOf) && !t.isInterface(typeOf)
 }
 
 func (t *TypeScriptify) Convert(customCode map[string]string) (string, error) {
-	t.alreadyConverted = make(map[reflect.Type]bool)
+	t.report = newReport("")
+	start := time.Now()
+	result, err := t.convert(customCode)
+	t.report.finish(start, err)
+	return result, err
+}
+
+func (t *TypeScriptify) convert(customCode map[string]string) (string, error) {
+	if err := t.checkVersion(); err != nil {
+		return "", err
+	}
+	t.types[reflect.Interface] = t.interfaceType()
+	t.alreadyConverted = make(map[goType]bool)
+	t.declared = make(map[string]goType)
+	t.imports = nil
+	t.imported = make(map[string]bool)
+	t.usesBase64 = false
+	t.usesDate = false
+	t.usesDateParse = false
+	t.usesDateFormat = false
 
 	result := ""
 	for _, typeof := range t.golangTypes {
@@ -112,28 +231,28 @@ func (t *TypeScriptify) Convert(customCode map[string]string) (string, error) {
 		}
 		result += "\n" + strings.Trim(typeScriptCode, " "+t.Indent+"\r\n")
 	}
+	declarations := make([]string, 0)
+	if t.usesBase64 {
+		declarations = append(declarations, t.base64Declaration())
+	}
+	declarations = append(declarations, t.dateDeclarations()...)
+	if len(declarations) > 0 {
+		result = "\n" + strings.Join(declarations, "\n\n") + "\n" + result
+	}
+	if len(t.imports) > 0 {
+		result = strings.Join(t.imports, "\n") + "\n" + result
+	}
 	return result, nil
 }
 
-func loadCustomCode(fileName string) (map[string]string, error) {
+// parseCustomCode returns the custom code blocks of generated content by the
+// name of their entities.
+func parseCustomCode(content []byte) map[string]string {
 	result := make(map[string]string)
-	f, err := os.Open(fileName)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return result, nil
-		}
-		return result, err
-	}
-	defer f.Close()
-
-	bytes, err := ioutil.ReadAll(f)
-	if err != nil {
-		return result, err
-	}
 
 	var currentName string
 	var currentValue string
-	lines := strings.Split(string(bytes), "\n")
+	lines := strings.Split(string(content), "\n")
 	for _, line := range lines {
 		trimmedLine := strings.TrimSpace(line)
 		if strings.HasPrefix(trimmedLine, "//[") && strings.HasSuffix(trimmedLine, ":]") {
@@ -148,81 +267,165 @@ func loadCustomCode(fileName string) (map[string]string, error) {
 		}
 	}
 
-	return result, nil
+	return result
 }
 
-func (t TypeScriptify) backup(fileName string) error {
-	fileIn, err := os.Open(fileName)
+// readExisting returns the content of fileName, nil if it doesn't exist.
+func readExisting(fileName string) ([]byte, error) {
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil && os.IsNotExist(err) {
+		return nil, nil
+	}
+	return content, err
+}
+
+// render converts all added types into the full content of a file, keeping
+// the custom code blocks found in its existing content.
+func (t *TypeScriptify) render(existing []byte) ([]byte, error) {
+	converted, err := t.convert(parseCustomCode(existing))
 	if err != nil {
-		if !os.IsNotExist(err) {
-			return err
+		return nil, err
+	}
+
+	t.report.Fingerprint = t.fingerprint(converted)
+	header := "/* Do not change, this code is generated from Golang structs */\n" +
+		fmt.Sprintf("/* tscriptify %s, fingerprint %s */\n\n", Version, t.report.Fingerprint)
+	return []byte(header + converted), nil
+}
+
+// fingerprint hashes the generator version, the options and the generated
+// declarations, without custom code. It changes only when the output of the
+// conversion does.
+func (t *TypeScriptify) fingerprint(converted string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%q %q %q %t %t %t\n", Version, t.Prefix, t.Suffix, t.Indent, t.CreateFromMethod, t.DoExportClass, t.UseInterface)
+	var inCustomCode bool
+	for _, line := range strings.Split(converted, "\n") {
+		trimmedLine := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmedLine, "//[") && strings.HasSuffix(trimmedLine, ":]") {
+			inCustomCode = true
+		} else if trimmedLine == "//[end]" {
+			inCustomCode = false
+		} else if inCustomCode {
+			continue
 		}
-		// No neet to backup, just return:
-		return nil
+		io.WriteString(h, line+"\n")
 	}
-	defer fileIn.Close()
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
 
-	bytes, err := ioutil.ReadAll(fileIn)
+// MergeExisting converts all added types into the full content of a file,
+// keeping the custom code blocks of its existing content, which may be nil.
+// Nothing is read or written.
+func (t *TypeScriptify) MergeExisting(existing []byte) ([]byte, error) {
+	t.report = newReport("")
+	start := time.Now()
+	content, err := t.render(existing)
+	t.report.finish(start, err)
+	return content, err
+}
+
+// ConvertTo converts all added types and writes the content of a new file,
+// without custom code, to w.
+func (t *TypeScriptify) ConvertTo(w io.Writer) error {
+	content, err := t.MergeExisting(nil)
 	if err != nil {
 		return err
 	}
+	_, err = w.Write(content)
+	return err
+}
 
-	fileOut, err := os.Create(fmt.Sprintf("%s-%s.%s", fileName, time.Now().Format("2006-01-02T15_04_05.99"), t.BackupExtension))
+// ConvertToFile converts all added types and writes them to fileName. The
+// whole output is rendered in memory first and then moved into place with an
+// atomic rename, so on any error the existing file is left untouched. If the
+// content doesn't change the file isn't written and no backup is made.
+// Concurrent writers of the same file are serialized with a lock file.
+func (t *TypeScriptify) ConvertToFile(fileName string) (err error) {
+	t.report = newReport(fileName)
+	defer func(start time.Time) {
+		t.report.finish(start, err)
+	}(time.Now())
+
+	unlock, err := lockFile(fileName)
 	if err != nil {
 		return err
 	}
-	defer fileOut.Close()
+	defer unlock()
 
-	_, err = fileOut.Write(bytes)
+	existing, err := readExisting(fileName)
 	if err != nil {
 		return err
 	}
 
-	return nil
-}
+	content, err := t.render(existing)
+	if err != nil {
+		return err
+	}
+	t.report.Changed = !bytes.Equal(existing, content)
+	if !t.report.Changed {
+		// Not touched, so watchers aren't triggered
+		return nil
+	}
 
-func (t TypeScriptify) ConvertToFile(fileName string) error {
 	if len(t.BackupExtension) > 0 {
-		err := t.backup(fileName)
+		err := t.backup(fileName, content)
 		if err != nil {
 			return err
 		}
 	}
 
-	customCode, err := loadCustomCode(fileName)
-	if err != nil {
-		return err
-	}
+	return writeFileAtomic(fileName, content)
+}
 
-	f, err := os.Create(fileName)
+// Verify renders the output for fileName in memory and compares it with the
+// file on disk, custom code blocks included. It returns a unified diff, which
+// is empty when the file is up to date. Nothing is written.
+func (t *TypeScriptify) Verify(fileName string) (diff string, err error) {
+	t.report = newReport(fileName)
+	defer func(start time.Time) {
+		t.report.finish(start, err)
+	}(time.Now())
+
+	existing, err := readExisting(fileName)
 	if err != nil {
-		return err
+		return "", err
 	}
-	defer f.Close()
 
-	converted, err := t.Convert(customCode)
+	content, err := t.render(existing)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	f.WriteString("/* Do not change, this code is generated from Golang structs */\n\n")
-	f.WriteString(converted)
-	if err != nil {
-		return err
+	if bytes.Equal(existing, content) {
+		return "", nil
 	}
 
-	return nil
+	diff = unifiedDiff(fileName, fileName+" (generated)", string(existing), string(content))
+	t.report.Changed = true
+	t.report.Diff = diff
+	return diff, nil
 }
 
-func (t *TypeScriptify) convertType(typeOf reflect.Type, customCode map[string]string) (string, error) {
+func (t *TypeScriptify) convertType(typeOf goType, customCode map[string]string) (string, error) {
 	if typeOf.Kind() == reflect.Interface {
 		return "", nil
 	}
 
-	for _, v := range t.dateTypes {
-		if v.String() == typeOf.String() {
-			return "", nil
-		}
+	if _, mapped, err := t.mapping(typeOf); err != nil || mapped {
+		return "", err
+	}
+
+	isEnum := isEnumType(typeOf)
+	isAlias := t.isAlias(typeOf)
+
+	if !isEnum && !isAlias && typeOf.Kind() != reflect.Struct && typeOf.Kind() != reflect.Ptr {
+		// Named slices, maps and basic types have no declaration of their own
+		return "", nil
+	}
+
+	if t.isDate(typeOf) {
+		return "", nil
 	}
 
 	if _, found := t.alreadyConverted[typeOf]; found {
@@ -230,32 +433,55 @@ func (t *TypeScriptify) convertType(typeOf reflect.Type, customCode map[string]s
 	}
 	t.alreadyConverted[typeOf] = true
 
-	entityName := fmt.Sprintf("%s%s%s", t.Prefix, t.Suffix, typeOf.Name())
+	entityName := t.entityName(typeOf)
+
+	// Types from different packages may share a name
+	if other, found := t.declared[entityName]; found {
+		return "", fmt.Errorf("%s.%s and %s.%s are both converted to %s, rename one of them", other.PkgPath(), other.Name(), typeOf.PkgPath(), typeOf.Name(), entityName)
+	}
+	t.declared[entityName] = typeOf
 
 	// Set type of typescript kind
 	// class, interface, enum
 	typeKind := "class"
-	if t.UseInterface {
+	if t.isInterface(typeOf) {
 		typeKind = "interface"
 	}
 
-	stringer := reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
-
-	if typeOf.Kind() == reflect.Int && typeOf.Implements(stringer) {
+	if isEnum {
 		typeKind = "enum"
 	}
 
+	if isAlias {
+		typeKind = "type"
+	}
+
+	t.report.Entities = append(t.report.Entities, ReportEntity{
+		Name:    entityName,
+		Kind:    typeKind,
+		GoType:  typeOf.Name(),
+		Package: typeOf.PkgPath(),
+	})
+
+	if isAlias {
+		return t.convertAlias(typeOf, entityName, customCode)
+	}
+
 	result := fmt.Sprintf("%s %s {\n", typeKind, entityName)
 	if t.DoExportClass {
 		result = "export " + result
 	}
 	builder := typeScriptClassBuilder{
-		types:  t.types,
-		indent: t.Indent,
+		types:    t.types,
+		indent:   t.Indent,
+		dateType: t.DateType,
+		strict:   t.Strict,
+		definite: t.Strict && typeKind == "class" && t.supports(2, 7),
 	}
 
 	fields := deepFields(typeOf)
 	for _, field := range fields {
+		builder.alias = ""
 		jsonTag := field.Tag.Get("json")
 		jsonFieldName := ""
 		fieldType := field.Type
@@ -270,77 +496,191 @@ func (t *TypeScriptify) convertType(typeOf reflect.Type, customCode map[string]s
 			}
 		}
 
-		if len(jsonFieldName) > 0 && jsonFieldName != "-" {
-			var err error
+		// Like encoding/json, which also encodes the fields without tag
+		if !field.Exported || jsonFieldName == "-" {
+			continue
+		}
+		if unsupported := unsupportedType(fieldType); unsupported != nil {
+			message := fmt.Sprintf("%s.%s: %s can't be encoded by encoding/json, skip the field with `json:\"-\"`", typeOf.Name(), field.Name, unsupported.String())
+			if !t.SkipUnsupported {
+				return "", errors.New(message)
+			}
+			t.warn("%s, skipped", message)
+			continue
+		}
+
+		if len(jsonFieldName) > 0 {
+			if isDate, nullable := t.dateOf(fieldType); isDate {
+				builder.AddDateField(jsonFieldName, t.fieldDateType(typeOf, field, nullable, typeKind != "class"))
+				continue
+			}
+
+			mapping, mapped, err := t.mapping(fieldType)
+			if err != nil {
+				return "", fmt.Errorf("%s.%s: %s", typeOf.Name(), field.Name, err.Error())
+			}
+			if mapped && mapping.base64 && typeKind == "class" {
+				builder.AddBase64Field(jsonFieldName)
+				continue
+			}
+			if mapped {
+				builder.AddStructField(jsonFieldName, mapping.tsType(), false)
+				continue
+			}
+
+			quoted := isQuoted(jsonTag, fieldType.Kind())
+			if isInt64(fieldType.Kind()) || (fieldType.Kind() == reflect.Slice || fieldType.Kind() == reflect.Map) && isInt64(fieldType.Elem().Kind()) {
+				int64Type, err := t.int64Type(field)
+				if err != nil {
+					return "", fmt.Errorf("%s.%s: %s", typeOf.Name(), field.Name, err.Error())
+				}
+				switch {
+				case int64Type != Int64Number && !quoted:
+					// JSON.parse rounds numbers above 2^53 before createFrom could convert them
+					t.warn("%s.%s: %s needs the values sent as strings with the ,string option, declared as number", typeOf.Name(), field.Name, int64Type)
+				case int64Type == Int64BigInt && typeKind != "class":
+					t.warn("%s.%s: bigint isn't converted from JSON in interfaces, declared as string", typeOf.Name(), field.Name)
+				case int64Type == Int64BigInt:
+					builder.AddBigIntField(jsonFieldName)
+					continue
+				}
+			}
+			if quoted {
+				builder.AddStructField(jsonFieldName, "string", false)
+				continue
+			}
+
+			if t.isAlias(fieldType) {
+				typeScriptChunk, err := t.convertType(fieldType, customCode)
+				if err != nil {
+					return "", err
+				}
+				result = prepend(typeScriptChunk, result)
+				// Converted like the underlying type, only declared as the alias
+				builder.alias = t.entityName(fieldType)
+			}
+
 			switch fieldType.Kind() {
 			case reflect.Map:
-				keyType := "string"
-				if k, ok := t.types[fieldType.Key().Kind()]; ok {
-					keyType = k
+				keyType, err := t.mapKeyType(fieldType.Key())
+				if err != nil {
+					return "", fmt.Errorf("%s.%s: %s", typeOf.Name(), field.Name, err.Error())
+				}
+				if isEnumKey(fieldType.Key()) {
+					typeScriptChunk, err := t.convertType(fieldType.Key(), customCode)
+					if err != nil {
+						return "", err
+					}
+					result = prepend(typeScriptChunk, result)
 				}
 
-				valType := "any"
+				valType := t.types[reflect.Interface]
 				mapValType := fieldType.Elem()
 
 				if mapValType.Kind() == reflect.Ptr {
 					mapValType = mapValType.Elem()
 				}
-				if mapValType.Kind() == reflect.Struct {
-					valType = mapValType.Name()
-
+				if isDate, nullable := t.dateOf(mapValType); isDate {
+					builder.AddDateMapField(jsonFieldName, mapType(fieldType.Key(), keyType, t.fieldDateType(typeOf, field, nullable, typeKind != "class")))
+					break
+				}
+				mapping, mapped, err = t.mapping(mapValType)
+				if err != nil {
+					return "", fmt.Errorf("%s.%s: %s", typeOf.Name(), field.Name, err.Error())
+				}
+				if mapped && mapping.base64 && typeKind == "class" {
+					builder.AddBase64MapField(jsonFieldName, mapType(fieldType.Key(), keyType, "Uint8Array"))
+					break
+				}
+				if mapped {
+					valType = mapping.tsType()
+				} else if t.isAlias(mapValType) {
+					valType = t.entityName(mapValType)
 					typeScriptChunk, err := t.convertType(mapValType, customCode)
 					if err != nil {
 						return "", err
 					}
+					result = prepend(typeScriptChunk, result)
+					if typeKind == "class" && t.convertsValues(mapValType) {
+						t.warn("%s.%s: values of %s in maps aren't converted", typeOf.Name(), field.Name, mapValType.Name())
+					}
+					// Not overridden by the basic type below
+					mapped = true
+				} else if mapValType.Kind() == reflect.Struct {
+					valType = t.entityName(mapValType)
 
-					for _, v := range t.dateTypes {
-						if v.String() != mapValType.String() {
-							continue
-						}
-
-						valType = "Date"
+					typeScriptChunk, err := t.convertType(mapValType, customCode)
+					if err != nil {
+						return "", err
 					}
 
 					result = typeScriptChunk + "\n" + result
 				}
-				if v, ok := t.types[mapValType.Kind()]; ok {
+				if v, ok := t.types[mapValType.Kind()]; ok && !mapped {
 					valType = v
+				} else if !mapped && mapValType.Kind() != reflect.Struct {
+					t.warn("%s.%s: map value %s declared as any", typeOf.Name(), field.Name, mapValType.String())
 				}
 
-				builder.AddStructField(jsonFieldName, fmt.Sprintf("{[key: %s]: %s}", keyType, valType))
+				builder.AddStructField(jsonFieldName, mapType(fieldType.Key(), keyType, valType), false)
 			case reflect.Interface:
-				builder.AddStructField(jsonFieldName, "any")
+				builder.AddStructField(jsonFieldName, t.types[reflect.Interface], false)
 			case reflect.Struct:
-				name := fieldType.Name()
+				name := t.entityName(fieldType)
 				typeScriptChunk, err := t.convertType(fieldType, customCode)
 				if err != nil {
 					return "", err
 				}
 
-				for _, v := range t.dateTypes {
-					if v.String() != fieldType.String() {
-						continue
-					}
-
-					name = "Date"
-				}
-
 				result = typeScriptChunk + "\n" + result
-				builder.AddStructField(jsonFieldName, name)
+				builder.AddStructField(jsonFieldName, name, t.hasCreateFrom(fieldType))
 			case reflect.Slice:
 				elemType := fieldType.Elem()
 				if elemType.Kind() == reflect.Ptr {
 					elemType = elemType.Elem()
 				}
 
+				if isDate, nullable := t.dateOf(elemType); isDate {
+					dateType := t.fieldDateType(typeOf, field, nullable, typeKind != "class")
+					if nullable {
+						dateType = "(" + dateType + ")"
+					}
+					builder.AddDateArrayField(jsonFieldName, dateType+"[]")
+					break
+				}
+				mapping, mapped, err = t.mapping(elemType)
+				if err != nil {
+					return "", fmt.Errorf("%s.%s: %s", typeOf.Name(), field.Name, err.Error())
+				}
+				if mapped && mapping.base64 && typeKind == "class" {
+					builder.AddBase64ArrayField(jsonFieldName)
+					break
+				}
+				if mapped {
+					builder.AddStructField(jsonFieldName, mapping.arrayType(), false)
+					break
+				}
+				if t.isAlias(elemType) {
+					typeScriptChunk, err := t.convertType(elemType, customCode)
+					if err != nil {
+						return "", err
+					}
+					result = prepend(typeScriptChunk, result)
+					if typeKind == "class" && t.convertsValues(elemType) {
+						t.warn("%s.%s: values of %s in arrays aren't converted", typeOf.Name(), field.Name, elemType.Name())
+					}
+					builder.AddStructField(jsonFieldName, t.entityName(elemType)+"[]", false)
+					break
+				}
+
 				switch elemType.Kind() {
 				case reflect.Struct:
 					typeScriptChunk, err := t.convertType(elemType, customCode)
 					if err != nil {
 						return "", err
 					}
-					result = typeScriptChunk + "\n" + result
-					builder.AddArrayOfStructsField(jsonFieldName, elemType.Name())
+					result = prepend(typeScriptChunk, result)
+					builder.AddArrayOfStructsField(jsonFieldName, t.entityName(elemType), t.hasCreateFrom(elemType))
 				default:
 					err = builder.AddSimpleArrayField(jsonFieldName, elemType.Name(), elemType.Kind())
 				}
@@ -351,8 +691,8 @@ func (t *TypeScriptify) convertType(typeOf reflect.Type, customCode map[string]s
 					if err != nil {
 						return "", err
 					}
-					result = tsChunk + "\n" + result
-					builder.AddStructField(jsonFieldName, fieldType.Name())
+					result = prepend(tsChunk, result)
+					builder.AddStructField(jsonFieldName, t.entityName(fieldType), false)
 				} else {
 					err = builder.AddSimpleField(jsonFieldName, fieldType.Name(), fieldType.Kind())
 				}
@@ -367,28 +707,35 @@ func (t *TypeScriptify) convertType(typeOf reflect.Type, customCode map[string]s
 	}
 
 	result += builder.fields
-	if t.CreateFromMethod && !t.UseInterface {
+	if t.CreateFromMethod && typeKind == "class" {
 		result += fmt.Sprintf("\n%sstatic createFrom(source: any) {\n", t.Indent)
 		result += fmt.Sprintf("%s%slet result = new %s();\n", t.Indent, t.Indent, entityName)
 		result += builder.createFromMethodBody
 		result += fmt.Sprintf("%s%sreturn result;\n", t.Indent, t.Indent)
 		result += fmt.Sprintf("%s}\n\n", t.Indent)
 	}
+	if typeKind == "class" {
+		t.usesDateParse = t.usesDateParse || t.CreateFromMethod && builder.usesDateParse
+		t.usesDateFormat = t.usesDateFormat || builder.usesDateFormat
+	}
+	if typeKind == "class" && len(builder.toJSONFields) > 0 {
+		if !t.CreateFromMethod {
+			result += "\n"
+		}
+		result += builder.toJSONMethod()
+	}
 
 	// Set all enum values
 	if typeKind == "enum" {
-		val := reflect.New(typeOf).Elem()
-		for i := 0; i < 10000; i++ {
-			val.SetInt(int64(i))
-			arr := val.MethodByName("String").Call(nil)
-			if len(arr) == 1 {
-				enumVal := fmt.Sprintf("%+v", arr[0])
-
-				if strings.Contains(enumVal, fmt.Sprintf("(%d)", i)) {
-					continue
-				}
-				result += fmt.Sprintf("%s%s = '%s',\n", t.Indent, ToCamel(enumVal), enumVal)
-			}
+		values, err := typeOf.EnumValues()
+		if err != nil {
+			return "", err
+		}
+		if len(values) == 0 {
+			t.warn("enum %s has no values", typeOf.Name())
+		}
+		for _, enumVal := range values {
+			result += fmt.Sprintf("%s%s = '%s',\n", t.Indent, ToCamel(enumVal), enumVal)
 		}
 	}
 
@@ -408,17 +755,86 @@ func (t *TypeScriptify) convertType(typeOf reflect.Type, customCode map[string]s
 	return result, nil
 }
 
+// unsupportedType returns the channel, function, complex or unsafe pointer
+// type within a type, nil if there is none. Struct fields are checked when
+// their struct is converted.
+func unsupportedType(typeOf goType) goType {
+	switch typeOf.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+		return typeOf
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return unsupportedType(typeOf.Elem())
+	}
+	return nil
+}
+
+// prepend adds the declaration of a referenced type before result, already
+// declared types are empty.
+func prepend(declaration, result string) string {
+	if len(declaration) == 0 {
+		return result
+	}
+	return declaration + "\n" + result
+}
+
+// mapKeyType returns the TypeScript type of the keys of a map, they are
+// strings in JSON. Integer keys, of enums too, are decimal numbers unless
+// they are encoding.TextMarshalers. Enums encoded as their String() keep
+// their enum.
+func (t *TypeScriptify) mapKeyType(keyType goType) (string, error) {
+	if keyType.Kind() == reflect.String {
+		return "string", nil
+	}
+	if isEnumKey(keyType) {
+		return t.entityName(keyType), nil
+	}
+	if marshals(keyType, textMarshaler) {
+		return "string", nil
+	}
+	switch keyType.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if t.supports(4, 4) {
+			return "`${number}`", nil
+		}
+		return "string", nil
+	}
+	return "", fmt.Errorf("map key %s isn't supported by encoding/json, use a string, an integer or an encoding.TextMarshaler", keyType.String())
+}
+
+// isEnumKey reports whether the JSON keys of maps with this key type are the
+// values of its TypeScript enum.
+func isEnumKey(keyType goType) bool {
+	return isEnumType(keyType) && marshals(keyType, textMarshaler) && keyType.EnumText()
+}
+
+// mapType returns the TypeScript type of a map. Not all values of an enum
+// have to be keys.
+func mapType(keyType goType, tsKeyType, tsValueType string) string {
+	if isEnumKey(keyType) {
+		return fmt.Sprintf("Partial<Record<%s, %s>>", tsKeyType, tsValueType)
+	}
+	return fmt.Sprintf("{[key: %s]: %s}", tsKeyType, tsValueType)
+}
+
 type typeScriptClassBuilder struct {
 	types                map[reflect.Kind]string
 	indent               string
 	fields               string
 	createFromMethodBody string
+	toJSONFields         string
+	dateType             DateType
+	alias                string // Declared type of the current field if it is an alias
+	strict               bool
+	definite             bool
+	usesDateParse        bool
+	usesDateFormat       bool
 }
 
 func (t *typeScriptClassBuilder) AddSimpleArrayField(fieldName, fieldType string, kind reflect.Kind) error {
 	if typeScriptType, ok := t.types[kind]; ok {
 		if len(fieldName) > 0 {
-			t.fields += fmt.Sprintf("%s%s: %s[];\n", t.indent, fieldName, typeScriptType)
+			t.addField(fieldName, typeScriptType+"[]")
 			t.createFromMethodBody += fmt.Sprintf("%s%sresult.%s = source[\"%s\"];\n", t.indent, t.indent, fieldName, fieldName)
 			return nil
 		}
@@ -429,7 +845,7 @@ func (t *typeScriptClassBuilder) AddSimpleArrayField(fieldName, fieldType string
 func (t *typeScriptClassBuilder) AddSimpleField(fieldName, fieldType string, kind reflect.Kind) error {
 	if typeScriptType, ok := t.types[kind]; ok {
 		if len(fieldName) > 0 {
-			t.fields += fmt.Sprintf("%s%s: %s;\n", t.indent, fieldName, typeScriptType)
+			t.addField(fieldName, typeScriptType)
 			t.createFromMethodBody += fmt.Sprintf("%s%sresult.%s = source[\"%s\"];\n", t.indent, t.indent, fieldName, fieldName)
 			return nil
 		}
@@ -437,14 +853,22 @@ func (t *typeScriptClassBuilder) AddSimpleField(fieldName, fieldType string, kin
 	return errors.New(fmt.Sprintf("Cannot find type '%s' for field '%s' ", fieldType, fieldName))
 }
 
-func (t *typeScriptClassBuilder) AddStructField(fieldName, fieldType string) {
-	t.fields += fmt.Sprintf("%s%s: %s;\n", t.indent, fieldName, fieldType)
-	t.createFromMethodBody += fmt.Sprintf("%s%sresult.%s = source[\"%s\"] ? %s.createFrom(source[\"%s\"]) : null;\n", t.indent, t.indent, fieldName, fieldName, fieldType, fieldName)
+func (t *typeScriptClassBuilder) AddStructField(fieldName, fieldType string, createFrom bool) {
+	t.addField(fieldName, fieldType)
+	if !createFrom {
+		t.createFromMethodBody += fmt.Sprintf("%s%sresult.%s = source[\"%s\"];\n", t.indent, t.indent, fieldName, fieldName)
+		return
+	}
+	t.createFromMethodBody += fmt.Sprintf("%s%sresult.%s = source[\"%s\"] ? %s.createFrom(source[\"%s\"]) : %s;\n", t.indent, t.indent, fieldName, fieldName, fieldType, fieldName, t.orElse(fieldName))
 }
 
-func (t *typeScriptClassBuilder) AddArrayOfStructsField(fieldName, fieldType string) {
-	t.fields += fmt.Sprintf("%s%s: %s[];\n", t.indent, fieldName, fieldType)
-	t.createFromMethodBody += fmt.Sprintf("%s%sresult.%s = source[\"%s\"] ? source[\"%s\"].map(function(element) { return %s.createFrom(element); }) : null;\n", t.indent, t.indent, fieldName, fieldName, fieldName, fieldType)
+func (t *typeScriptClassBuilder) AddArrayOfStructsField(fieldName, fieldType string, createFrom bool) {
+	t.addField(fieldName, fieldType+"[]")
+	if !createFrom {
+		t.createFromMethodBody += fmt.Sprintf("%s%sresult.%s = source[\"%s\"];\n", t.indent, t.indent, fieldName, fieldName)
+		return
+	}
+	t.createFromMethodBody += fmt.Sprintf("%s%sresult.%s = source[\"%s\"] ? source[\"%s\"].map(function(%s) { return %s.createFrom(element); }) : %s;\n", t.indent, t.indent, fieldName, fieldName, fieldName, t.elementParam(), fieldType, t.orElse(fieldName))
 }
 
 
@@ -1,13 +1,24 @@
 package typescriptify
 
 import (
+	"bytes"
 	"bitbucket.org/amanbolat/caconsole/shipment/model"
+	"database/sql"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/amanbolat/go-tscriptify/typescriptify/testdata/models"
+	"github.com/guregu/null"
 )
 
 type Address struct {
@@ -193,3 +204,992 @@ func TestEnum(t *testing.T) {
 	typeOf := reflect.TypeOf((*model.PaymentMethod)(nil))
 	t.Logf("%+v", typeOf.Elem().Name())
 }
+
+type Unsupported struct {
+	Channel chan int `json:"channel"`
+}
+
+func TestConvertToFileKeepsContentOnError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "typescriptify")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	fileName := filepath.Join(dir, "models.ts")
+	original := "export class Existing {}\n"
+	if err := ioutil.WriteFile(fileName, []byte(original), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	converter := New()
+	converter.Add(Unsupported{})
+	if err := converter.ConvertToFile(fileName); err == nil {
+		t.Fatal("expected conversion error")
+	}
+
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if string(content) != original {
+		t.Errorf("target file changed: %q", string(content))
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("expected only the target file, got %d files", len(files))
+	}
+}
+
+func TestConvertToFileConcurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "typescriptify")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	fileName := filepath.Join(dir, "models.ts")
+
+	errs := make(chan error, 4)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			converter := New()
+			converter.BackupExtension = ""
+			converter.Add(Address{})
+			errs <- converter.ConvertToFile(fileName)
+		}()
+	}
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Error(err.Error())
+		}
+	}
+
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(string(content), "export class Address {") {
+		t.Errorf("unexpected content:\n%s", string(content))
+	}
+	if _, err := os.Stat(fileName + ".lock"); !os.IsNotExist(err) {
+		t.Error("lock file not removed")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "typescriptify")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	fileName := filepath.Join(dir, "models.ts")
+
+	converter := New()
+	converter.Add(Address{})
+
+	diff, err := converter.Verify(fileName)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(diff) == 0 {
+		t.Error("expected a diff for a missing file")
+	}
+	if _, err := os.Stat(fileName); !os.IsNotExist(err) {
+		t.Error("Verify must not write the file")
+	}
+
+	if err := converter.ConvertToFile(fileName); err != nil {
+		t.Fatal(err.Error())
+	}
+	diff, err = converter.Verify(fileName)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(diff) > 0 {
+		t.Errorf("expected no diff, got:\n%s", diff)
+	}
+
+	converter.Add(Dummy{})
+	diff, err = converter.Verify(fileName)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(diff, "+export class Dummy {") {
+		t.Errorf("expected Dummy in diff, got:\n%s", diff)
+	}
+}
+
+func TestTypeOptions(t *testing.T) {
+	converter := New()
+	converter.Prefix = "API_"
+	converter.AddTypeWithOptions(reflect.TypeOf(Dummy{}), TypeOptions{Name: "Something", Interface: true})
+	converter.Add(Person{})
+	converter.Add(Address{})
+
+	desiredResult := `export function parseDate(value: string): Date {
+        return new Date(value);
+}
+
+export interface API_Something {
+        something: string;
+        some_interface: any;
+}
+export class API_Address {
+        duration: number;
+        text: string;
+
+        static createFrom(source: any) {
+                let result = new API_Address();
+                result.duration = source["duration"];
+                result.text = source["text"];
+                return result;
+        }
+
+}
+export class API_Person {
+        name: string;
+        nicknames: string[];
+        addresses: API_Address[];
+        a: API_Something;
+        b: API_Something;
+        slice_ptr: API_Something[];
+        map: {[key: string]: API_Something};
+        birthday: Date;
+
+        static createFrom(source: any) {
+                let result = new API_Person();
+                result.name = source["name"];
+                result.nicknames = source["nicknames"];
+                result.addresses = source["addresses"] ? source["addresses"].map(function(element) { return API_Address.createFrom(element); }) : null;
+                result.a = source["a"];
+                result.b = source["b"];
+                result.slice_ptr = source["slice_ptr"];
+                result.map = source["map"];
+                result.birthday = source["birthday"] != null ? parseDate(source["birthday"]) : null;
+                return result;
+        }
+
+}`
+	testConverter(t, converter, desiredResult)
+}
+
+func TestNameClash(t *testing.T) {
+	converter := New()
+	converter.AddTypeWithOptions(reflect.TypeOf(Dummy{}), TypeOptions{Name: "Address"})
+	converter.Add(Address{})
+
+	if _, err := converter.Convert(nil); err == nil || !strings.Contains(err.Error(), "both converted to Address") {
+		t.Errorf("expected a name clash error, got %v", err)
+	}
+}
+
+func TestReport(t *testing.T) {
+	type Unusual struct {
+		Lists map[string][]string `json:"lists"`
+	}
+
+	dir, err := ioutil.TempDir("", "tscriptify")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "models.ts")
+
+	converter := New()
+	converter.BackupExtension = ""
+	converter.Add(Person{})
+	converter.Add(Unusual{})
+	if err := converter.ConvertToFile(fileName); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	report := converter.Report()
+	if report.Output != fileName || !report.Changed || len(report.Error) > 0 {
+		t.Errorf("unexpected report %+v", report)
+	}
+	names := make([]string, 0)
+	for _, e := range report.Entities {
+		names = append(names, e.Kind+" "+e.Name)
+		if e.GoType == "Person" && e.Package != reflect.TypeOf(Person{}).PkgPath() {
+			t.Errorf("unexpected package %s", e.Package)
+		}
+	}
+	if expected := []string{"class Person", "class Address", "class Dummy", "class Unusual"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected entities %v, got %v", expected, names)
+	}
+	if len(report.Warnings) != 1 || !strings.Contains(report.Warnings[0], "Unusual.Lists") {
+		t.Errorf("unexpected warnings %v", report.Warnings)
+	}
+
+	if err := converter.ConvertToFile(fileName); err != nil {
+		t.Fatal(err.Error())
+	}
+	if converter.Report().Changed {
+		t.Error("expected an unchanged file")
+	}
+}
+
+func TestMergeExisting(t *testing.T) {
+	converter := New()
+	converter.CreateFromMethod = false
+	converter.Add(Dummy{})
+
+	var buf bytes.Buffer
+	if err := converter.ConvertTo(&buf); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(buf.String(), "export class Dummy {") || !strings.Contains(buf.String(), "//[Dummy:]") {
+		t.Errorf("unexpected output %s", buf.String())
+	}
+
+	existing := strings.Replace(buf.String(), "//[Dummy:]\n", "//[Dummy:]\n    extra: number;\n", 1)
+	merged, err := converter.MergeExisting([]byte(existing))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(string(merged), "//[Dummy:]\n    extra: number;\n") {
+		t.Errorf("expected the custom code to be kept, got %s", string(merged))
+	}
+	again, err := converter.MergeExisting(merged)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Equal(again, merged) {
+		t.Errorf("expected merging to be stable, got %s", string(again))
+	}
+}
+
+func TestMemoryOutput(t *testing.T) {
+	output := MemoryOutput{}
+
+	first := New()
+	first.Add(Dummy{})
+	second := New()
+	second.Add(Address{})
+	if err := first.ConvertToOutput(output, "dummy.ts"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := second.ConvertToOutput(output, "address.ts"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if files := output.Files(); !reflect.DeepEqual(files, []string{"address.ts", "dummy.ts"}) {
+		t.Errorf("unexpected files %v", files)
+	}
+	if !strings.Contains(string(output["address.ts"]), "class Address") {
+		t.Errorf("unexpected content %s", string(output["address.ts"]))
+	}
+
+	if err := second.ConvertToOutput(output, "address.ts"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if second.Report().Changed {
+		t.Error("expected an unchanged file")
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tscriptify")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "models.ts")
+
+	converter := New()
+	converter.Add(Address{})
+	if err := converter.ConvertToFile(fileName); err != nil {
+		t.Fatal(err.Error())
+	}
+	fingerprint := converter.Report().Fingerprint
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(string(content), "fingerprint "+fingerprint+" */") {
+		t.Errorf("expected the fingerprint %s in the header of %s", fingerprint, string(content))
+	}
+
+	// Custom code doesn't change the fingerprint
+	custom := strings.Replace(string(content), "//[Address:]\n", "//[Address:]\n    street: string;\n", 1)
+	if err := ioutil.WriteFile(fileName, []byte(custom), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := converter.ConvertToFile(fileName); err != nil {
+		t.Fatal(err.Error())
+	}
+	if converter.Report().Fingerprint != fingerprint {
+		t.Error("expected custom code to keep the fingerprint")
+	}
+
+	// An unchanged file isn't written again
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(fileName, past, past); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := converter.ConvertToFile(fileName); err != nil {
+		t.Fatal(err.Error())
+	}
+	if info, err := os.Stat(fileName); err != nil || !info.ModTime().Equal(past) {
+		t.Error("expected the unchanged file not to be written")
+	}
+	backups, err := converter.Backups(fileName)
+	if err != nil || len(backups) != 1 {
+		t.Errorf("expected only the backup made for the custom code, got %v %v", backups, err)
+	}
+
+	converter.Prefix = "API_"
+	if _, err := converter.MergeExisting(nil); err != nil {
+		t.Fatal(err.Error())
+	}
+	if converter.Report().Fingerprint == fingerprint {
+		t.Error("expected the options to change the fingerprint")
+	}
+}
+
+func TestMappingPacks(t *testing.T) {
+	type Mapped struct {
+		Name     sql.NullString             `json:"name"`
+		Nickname null.String                `json:"nickname"`
+		Seen     null.Time                  `json:"seen"`
+		Timeout  time.Duration              `json:"timeout"`
+		Address  net.IP                     `json:"address"`
+		Peers    []net.IP                   `json:"peers"`
+		Balance  *big.Int                   `json:"balance"`
+		Aliases  []null.String              `json:"aliases"`
+		Extra    map[string]json.RawMessage `json:"extra"`
+	}
+
+	converter := New()
+	converter.CreateFromMethod = true
+	if err := converter.UseMappings("database/sql", "guregu/null", "time", "net", "math/big", "encoding/json"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := converter.UseMappings("nope"); err == nil {
+		t.Error("expected an error for an unknown pack")
+	}
+	converter.Add(Mapped{})
+
+	desiredResult := `export function parseDate(value: string): Date {
+        return new Date(value);
+}
+
+export class Mapped {
+        name: { String: string; Valid: boolean };
+        nickname: string | null;
+        seen: Date | null;
+        timeout: number;
+        address: string;
+        peers: string[];
+        balance: number;
+        aliases: (string | null)[];
+        extra: {[key: string]: unknown};
+
+        static createFrom(source: any) {
+                let result = new Mapped();
+                result.name = source["name"];
+                result.nickname = source["nickname"];
+                result.seen = source["seen"] != null ? parseDate(source["seen"]) : null;
+                result.timeout = source["timeout"];
+                result.address = source["address"];
+                result.peers = source["peers"];
+                result.balance = source["balance"];
+                result.aliases = source["aliases"];
+                result.extra = source["extra"];
+                return result;
+        }
+
+}`
+	testConverter(t, converter, desiredResult)
+}
+
+// Point marshals as an array
+type Point struct {
+	X int
+	Y int
+}
+
+func (p Point) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]int{p.X, p.Y})
+}
+
+// Code marshals as text
+type Code struct {
+	Value int
+}
+
+func (c Code) MarshalText() ([]byte, error) {
+	return []byte(strconv.Itoa(c.Value)), nil
+}
+
+// Weekday is an enum marshaling as text
+type Weekday int
+
+func (d Weekday) String() string {
+	if d < 0 || d > 1 {
+		return "Weekday(" + strconv.Itoa(int(d)) + ")"
+	}
+	return [...]string{"monday", "tuesday"}[d]
+}
+
+func (d Weekday) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// Level is an enum marshaling as text other than its String()
+type Level int
+
+func (l Level) String() string {
+	if l < 0 || l > 1 {
+		return "Level(" + strconv.Itoa(int(l)) + ")"
+	}
+	return [...]string{"debug", "info"}[l]
+}
+
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(strings.ToUpper(l.String())), nil
+}
+
+func TestMarshalers(t *testing.T) {
+	type Shape struct {
+		Code   Code           `json:"code"`
+		Codes  []Code         `json:"codes"`
+		ByCode map[Code]Point `json:"by_code"`
+	}
+
+	converter := New()
+	converter.CreateFromMethod = false
+	converter.Add(Shape{})
+	_, err := converter.Convert(nil)
+	if err == nil || !strings.Contains(err.Error(), "json.Marshaler") {
+		t.Fatalf("expected an error for the json.Marshaler, got %v", err)
+	}
+
+	converter.AddMapping("github.com/amanbolat/go-tscriptify/typescriptify.Point", Mapping{Type: "[number, number]"})
+	desiredResult := `export class Shape {
+        code: string;
+        codes: string[];
+        by_code: {[key: string]: [number, number]};
+}`
+	testConverter(t, converter, desiredResult)
+	if warnings := converter.Report().Warnings; len(warnings) > 0 {
+		t.Errorf("expected no warnings, got %v", warnings)
+	}
+}
+
+func TestTypeScriptTyper(t *testing.T) {
+	type Order struct {
+		Total    models.Money            `json:"total"`
+		Discount *models.Money           `json:"discount"`
+		Items    []models.Money          `json:"items"`
+		Taxes    map[string]models.Money `json:"taxes"`
+	}
+
+	converter := New()
+	converter.CreateFromMethod = false
+	converter.Add(Order{})
+	converter.Add(models.Money{})
+
+	desiredResult := `import { Money } from "./money";
+
+export class Order {
+        total: Money;
+        discount: Money;
+        items: Money[];
+        taxes: {[key: string]: Money};
+}`
+	testConverter(t, converter, desiredResult)
+}
+
+func TestBytes(t *testing.T) {
+	type Blob struct {
+		Data   []byte            `json:"data"`
+		Chunks [][]byte          `json:"chunks"`
+		ByName map[string][]byte `json:"by_name"`
+		Raw    json.RawMessage   `json:"raw"`
+	}
+
+	converter := New()
+	converter.CreateFromMethod = false
+	converter.Add(Blob{})
+
+	desiredResult := `export class Blob {
+        data: string;
+        chunks: string[];
+        by_name: {[key: string]: string};
+        raw: unknown;
+}`
+	testConverter(t, converter, desiredResult)
+
+	converter.Base64Type = true
+	converter.CreateFromMethod = true
+	converter.RawMessageType = "JsonValue"
+	desiredResult = `export type Base64 = string & { readonly __brand: "Base64" };
+
+export function decodeBase64(value: Base64): Uint8Array {
+        return Uint8Array.from(atob(value), (c) => c.charCodeAt(0));
+}
+
+export function encodeBase64(value: Uint8Array): Base64 {
+        return btoa(Array.from(value, (b) => String.fromCharCode(b)).join("")) as Base64;
+}
+
+export class Blob {
+        data: Uint8Array;
+        chunks: Uint8Array[];
+        by_name: {[key: string]: Uint8Array};
+        raw: JsonValue;
+
+        static createFrom(source: any) {
+                let result = new Blob();
+                result.data = source["data"] != null ? decodeBase64(source["data"]) : null;
+                result.chunks = source["chunks"] ? source["chunks"].map((element) => element != null ? decodeBase64(element) : null) : null;
+                result.by_name = source["by_name"] ? Object.fromEntries(Object.entries(source["by_name"]).map(([key, value]) => [key, value != null ? decodeBase64(value) : null])) : null;
+                result.raw = source["raw"];
+                return result;
+        }
+
+        toJSON() {
+                return {
+                        ...this,
+                        data: this.data != null ? encodeBase64(this.data) : null,
+                        chunks: this.chunks ? this.chunks.map((element) => element != null ? encodeBase64(element) : null) : null,
+                        by_name: this.by_name ? Object.fromEntries(Object.entries(this.by_name).map(([key, value]) => [key, value != null ? encodeBase64(value) : null])) : null,
+                };
+        }
+
+}`
+	testConverter(t, converter, desiredResult)
+
+	// Interfaces aren't converted, they declare the JSON strings
+	converter.UseInterface = true
+	desiredResult = desiredResult[:strings.Index(desiredResult, "export class")] + `export interface Blob {
+        data: Base64;
+        chunks: Base64[];
+        by_name: {[key: string]: Base64};
+        raw: JsonValue;
+}`
+	testConverter(t, converter, desiredResult)
+}
+
+func TestInt64(t *testing.T) {
+	type Tweet struct {
+		ID      int64            `json:"id,string"`
+		Likes   int64            `json:"likes"`
+		Replies []int64          `json:"replies"`
+		Parent  *uint64          `json:"parent,string" tscriptify:"int64=bigint"`
+		Ratio   float64          `json:"ratio,string"`
+		Counts  map[string]int64 `json:"counts"`
+	}
+
+	converter := New()
+	converter.Add(Tweet{})
+
+	desiredResult := `export class Tweet {
+        id: string;
+        likes: number;
+        replies: number[];
+        parent: bigint;
+        ratio: string;
+        counts: {[key: string]: number};
+
+        static createFrom(source: any) {
+                let result = new Tweet();
+                result.id = source["id"];
+                result.likes = source["likes"];
+                result.replies = source["replies"];
+                result.parent = source["parent"] != null ? BigInt(source["parent"]) : null;
+                result.ratio = source["ratio"];
+                result.counts = source["counts"];
+                return result;
+        }
+
+        toJSON() {
+                return {
+                        ...this,
+                        parent: this.parent != null ? String(this.parent) : null,
+                };
+        }
+
+}`
+	testConverter(t, converter, desiredResult)
+	if warnings := converter.Report().Warnings; len(warnings) > 0 {
+		t.Errorf("unexpected warnings %v", warnings)
+	}
+
+	// JSON numbers are rounded by JSON.parse, only strings are converted
+	converter.Int64Type = Int64String
+	testConverter(t, converter, desiredResult)
+	if warnings := converter.Report().Warnings; len(warnings) != 3 || !strings.Contains(warnings[0], "Tweet.Likes: string needs the values sent as strings") || !strings.Contains(warnings[2], "Tweet.Counts") {
+		t.Errorf("unexpected warnings %v", warnings)
+	}
+
+	converter.Int64Type = Int64BigInt
+	converter.CreateFromMethod = false
+	desiredResult = `export class Tweet {
+        id: bigint;
+        likes: number;
+        replies: number[];
+        parent: bigint;
+        ratio: string;
+        counts: {[key: string]: number};
+
+        toJSON() {
+                return {
+                        ...this,
+                        id: this.id != null ? String(this.id) : null,
+                        parent: this.parent != null ? String(this.parent) : null,
+                };
+        }
+
+}`
+	testConverter(t, converter, desiredResult)
+
+	// Interfaces can't convert, they declare the values as sent
+	converter.UseInterface = true
+	desiredResult = `export interface Tweet {
+        id: string;
+        likes: number;
+        replies: number[];
+        parent: string;
+        ratio: string;
+        counts: {[key: string]: number};
+}`
+	testConverter(t, converter, desiredResult)
+	if warnings := converter.Report().Warnings; len(warnings) != 5 || !strings.Contains(warnings[3], "Tweet.Parent: bigint isn't converted") {
+		t.Errorf("unexpected warnings %v", warnings)
+	}
+
+	converter.Int64Type = "float"
+	if _, err := converter.Convert(nil); err == nil {
+		t.Error("expected an error for an unknown int64 type")
+	}
+}
+
+func TestDateTypes(t *testing.T) {
+	type Event struct {
+		At      time.Time            `json:"at"`
+		History []time.Time          `json:"history"`
+		ByDay   map[string]time.Time `json:"by_day"`
+	}
+
+	converter := New()
+	converter.Add(Event{})
+	converter.DateType = DateType{
+		Type:   "DateTime",
+		Import: `import { DateTime } from "luxon";`,
+		Parse:  "DateTime.fromISO(value)",
+		Format: "value.toISO()",
+	}
+
+	desiredResult := `import { DateTime } from "luxon";
+
+export function parseDate(value: string): DateTime {
+        return DateTime.fromISO(value);
+}
+
+export function formatDate(value: DateTime): string {
+        return value.toISO();
+}
+
+export class Event {
+        at: DateTime;
+        history: DateTime[];
+        by_day: {[key: string]: DateTime};
+
+        static createFrom(source: any) {
+                let result = new Event();
+                result.at = source["at"] != null ? parseDate(source["at"]) : null;
+                result.history = source["history"] ? source["history"].map((element) => element != null ? parseDate(element) : null) : null;
+                result.by_day = source["by_day"] ? Object.fromEntries(Object.entries(source["by_day"]).map(([key, value]) => [key, value != null ? parseDate(value) : null])) : null;
+                return result;
+        }
+
+        toJSON() {
+                return {
+                        ...this,
+                        at: this.at != null ? formatDate(this.at) : null,
+                        history: this.history ? this.history.map((element) => element != null ? formatDate(element) : null) : null,
+                        by_day: this.by_day ? Object.fromEntries(Object.entries(this.by_day).map(([key, value]) => [key, value != null ? formatDate(value) : null])) : null,
+                };
+        }
+
+}`
+	testConverter(t, converter, desiredResult)
+
+	converter.DateType = DateISOString
+	converter.UseInterface = true
+	desiredResult = `export type ISODateString = string & { readonly __brand: "ISODateString" };
+
+export interface Event {
+        at: ISODateString;
+        history: ISODateString[];
+        by_day: {[key: string]: ISODateString};
+}`
+	testConverter(t, converter, desiredResult)
+
+	// Interfaces can't parse, they declare the JSON strings
+	converter.DateType = DateObject
+	desiredResult = `export interface Event {
+        at: string;
+        history: string[];
+        by_day: {[key: string]: string};
+}`
+	testConverter(t, converter, desiredResult)
+	if warnings := converter.Report().Warnings; len(warnings) != 3 || !strings.Contains(warnings[0], "Event.At: dates aren't parsed in interfaces") {
+		t.Errorf("unexpected warnings %v", warnings)
+	}
+
+	type Visits struct {
+		Seen  []*time.Time          `json:"seen"`
+		Until map[string]*time.Time `json:"until"`
+	}
+	converter = New()
+	converter.Add(Visits{})
+	output, err := converter.Convert(nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, expected := range []string{
+		`result.seen = source["seen"] ? source["seen"].map((element) => element != null ? parseDate(element) : null) : null;`,
+		`[key, value != null ? parseDate(value) : null]`,
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected %s in:\n%s", expected, output)
+		}
+	}
+}
+
+func TestStrict(t *testing.T) {
+	type Line struct {
+		Text string `json:"text"`
+	}
+	type Document struct {
+		Meta  interface{}            `json:"meta"`
+		Extra map[string]interface{} `json:"extra"`
+		First *Line                  `json:"first"`
+		Lines []Line                 `json:"lines"`
+	}
+
+	converter := New()
+	converter.UseInterface = false
+	converter.Strict = true
+	converter.TypeScriptVersion = "4.9"
+	converter.Add(Document{})
+
+	desiredResult := `export class Line {
+        text!: string;
+
+        static createFrom(source: any) {
+                let result = new Line();
+                result.text = source["text"];
+                return result;
+        }
+
+}
+export class Document {
+        meta!: unknown;
+        extra!: {[key: string]: unknown};
+        first!: Line;
+        lines!: Line[];
+
+        static createFrom(source: any) {
+                let result = new Document();
+                result.meta = source["meta"];
+                result.extra = source["extra"];
+                result.first = source["first"] ? Line.createFrom(source["first"]) : source["first"];
+                result.lines = source["lines"] ? source["lines"].map(function(element: any) { return Line.createFrom(element); }) : source["lines"];
+                return result;
+        }
+
+}`
+	testConverter(t, converter, desiredResult)
+
+	converter.TypeScriptVersion = "2.6"
+	converter.CreateFromMethod = false
+	desiredResult = `export class Line {
+        text: string;
+}
+export class Document {
+        meta: any;
+        extra: {[key: string]: any};
+        first: Line;
+        lines: Line[];
+}`
+	testConverter(t, converter, desiredResult)
+
+	converter.TypeScriptVersion = "latest"
+	if _, err := converter.Convert(nil); err == nil {
+		t.Error("expected an error for an invalid TypeScript version")
+	}
+}
+
+func TestMapKeys(t *testing.T) {
+	type Counters struct {
+		ByStatus  map[models.Status]int `json:"by_status"`
+		ByWeekday map[Weekday]int       `json:"by_weekday"`
+		ByLevel   map[Level]int         `json:"by_level"`
+		ByID      map[int64]string      `json:"by_id"`
+		ByCode    map[Code]bool         `json:"by_code"`
+	}
+
+	converter := New()
+	converter.CreateFromMethod = false
+	converter.Add(Counters{})
+
+	// Integer keys of enums are decimal numbers, unless they are encoded as
+	// the enum values
+	desiredResult := `export enum Weekday {
+        Monday = 'monday',
+        Tuesday = 'tuesday',
+}
+export class Counters {
+        by_status: {[key: ` + "`${number}`" + `]: number};
+        by_weekday: Partial<Record<Weekday, number>>;
+        by_level: {[key: string]: number};
+        by_id: {[key: ` + "`${number}`" + `]: string};
+        by_code: {[key: string]: boolean};
+}`
+	testConverter(t, converter, desiredResult)
+
+	converter.TypeScriptVersion = "4.3"
+	desiredResult = strings.Replace(desiredResult, "`${number}`", "string", -1)
+	testConverter(t, converter, desiredResult)
+
+	type Grid struct {
+		Cells map[Point]string `json:"cells"`
+	}
+	converter = New()
+	converter.Add(Grid{})
+	if _, err := converter.Convert(nil); err == nil || !strings.Contains(err.Error(), "Grid.Cells: map key") {
+		t.Errorf("expected an error for the struct key, got %v", err)
+	}
+}
+
+func TestAliases(t *testing.T) {
+	type Email string
+	type Tags []string
+	type Labels map[string]Email
+	type User struct {
+		Email  Email           `json:"email"`
+		Emails []Email         `json:"emails"`
+		Tags   Tags            `json:"tags"`
+		Labels Labels          `json:"labels"`
+		ByTag  map[string]Tags `json:"by_tag"`
+	}
+
+	converter := New()
+	converter.CreateFromMethod = false
+	converter.Add(User{})
+
+	desiredResult := `export class User {
+        email: string;
+        emails: string[];
+        tags: string[];
+        labels: {[key: string]: string};
+        by_tag: {[key: string]: any};
+}`
+	testConverter(t, converter, desiredResult)
+
+	converter.DeclareAliases = true
+	converter.BrandAliases = true
+	desiredResult = `export type Labels = {[key: string]: Email};
+export type Tags = string[];
+export type Email = string & { readonly __brand: "Email" };
+export class User {
+        email: Email;
+        emails: Email[];
+        tags: Tags;
+        labels: Labels;
+        by_tag: {[key: string]: Tags};
+}`
+	testConverter(t, converter, desiredResult)
+
+	// Values of aliases are converted like those of the underlying types
+	type Line struct {
+		Text string `json:"text"`
+	}
+	type Lines []Line
+	type Stamps []time.Time
+	type Count int
+	type Document struct {
+		Views   Count            `json:"views"`
+		Lines   Lines            `json:"lines"`
+		Stamps  Stamps           `json:"stamps"`
+		ByLine  map[string]Line  `json:"by_line"`
+		History map[string]Lines `json:"history"`
+	}
+	converter = New()
+	converter.DeclareAliases = true
+	converter.Add(Document{})
+	desiredResult = `export function parseDate(value: string): Date {
+        return new Date(value);
+}
+
+export type Stamps = Date[];
+export class Line {
+        text: string;
+
+        static createFrom(source: any) {
+                let result = new Line();
+                result.text = source["text"];
+                return result;
+        }
+
+}
+export type Lines = Line[];
+export type Count = number;
+export class Document {
+        views: Count;
+        lines: Lines;
+        stamps: Stamps;
+        by_line: {[key: string]: Line};
+        history: {[key: string]: Lines};
+
+        static createFrom(source: any) {
+                let result = new Document();
+                result.views = source["views"];
+                result.lines = source["lines"] ? source["lines"].map(function(element) { return Line.createFrom(element); }) : null;
+                result.stamps = source["stamps"] ? source["stamps"].map((element) => element != null ? parseDate(element) : null) : null;
+                result.by_line = source["by_line"];
+                result.history = source["history"];
+                return result;
+        }
+
+}`
+	testConverter(t, converter, desiredResult)
+	if warnings := converter.Report().Warnings; len(warnings) != 1 || !strings.Contains(warnings[0], "Document.History: values of Lines in maps aren't converted") {
+		t.Errorf("unexpected warnings %v", warnings)
+	}
+}
+
+func TestUnsupportedKinds(t *testing.T) {
+	type Job struct {
+		Name    string       `json:"name"`
+		Done    chan bool    `json:"-"`
+		Run     func() error `json:"run"`
+		Weights []complex128 `json:"weights"`
+		Notify  func(string)
+		done    chan bool
+	}
+
+	converter := New()
+	converter.CreateFromMethod = false
+	converter.Add(Job{})
+	if _, err := converter.Convert(nil); err == nil || !strings.Contains(err.Error(), "Job.Run: func() error can't be encoded") {
+		t.Errorf("expected an error for the func field, got %v", err)
+	}
+
+	converter.SkipUnsupported = true
+	desiredResult := `export class Job {
+        name: string;
+}`
+	testConverter(t, converter, desiredResult)
+	warnings := converter.Report().Warnings
+	if len(warnings) != 3 || !strings.Contains(warnings[0], "Job.Run") || !strings.Contains(warnings[1], "Job.Weights: complex128") || !strings.Contains(warnings[2], "Job.Notify") {
+		t.Errorf("unexpected warnings %v", warnings)
+	}
+}